@@ -2,7 +2,11 @@
 
 ROOTPATH=`pwd`
 
-tag=`date +'%Y-%m-%d-%H%M%S'`
+# 编译信息, 通过 config.Build() 读取, 输出到 version 子命令, 启动日志和 build_info 指标
+version=${VERSION:-`git describe --tags --always --dirty 2>/dev/null || echo dev`}
+commit=`git rev-parse HEAD 2>/dev/null`
+build_time=`date -u +'%Y-%m-%dT%H:%M:%SZ'`
+ldflags="-X init-golang/libs/config.Version=${version} -X init-golang/libs/config.Commit=${commit} -X init-golang/libs/config.BuildTime=${build_time}"
 
 mkdir -p ${ROOTPATH}/bin
 rm -rf ${ROOTPATH}/bin/$1*
@@ -11,13 +15,17 @@ go env | egrep "GOARCH|GOOS|GOHOSTARCH|GOHOSTOS"
 
 projects=(
   "example"
+  "auditverify"
+  "pricedecode"
+  "secretenc"
+  "checkconfig"
 )
 
 function build()
 {
   PROJ=$1
   NAME=$2
-  cd ${ROOTPATH}/cmd/${PROJ} && go build -o ${NAME} . && mv ${NAME} ${ROOTPATH}/bin
+  cd ${ROOTPATH}/cmd/${PROJ} && go build -ldflags "${ldflags}" -o ${NAME} . && mv ${NAME} ${ROOTPATH}/bin
 
   chmod +x ${ROOTPATH}/bin/${NAME}
 }
@@ -27,12 +35,10 @@ do
   if [ "$1" == "" ]; then
     # build ${proj} ${proj//find/replace};
     echo "building ${proj}"
-    # build ${proj} ${proj}-${tag}
     build ${proj} ${proj}
   elif [ "$1" == "${proj}" ]; then
     # build ${proj} ${proj//find/replace};
     echo "building ${proj}"
-    # build ${proj} ${proj}-${tag}
     build ${proj} ${proj}
   fi
 done
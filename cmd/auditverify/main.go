@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"init-golang/libs/logger"
+	"os"
+)
+
+// auditverify 校验审计日志的哈希链, 检查点签名以及是否被截断
+//
+//	auditverify -f logs/example_audit.log -k <key>
+func main() {
+	var filename, key string
+	flag.StringVar(&filename, "f", "", "audit log file")
+	flag.StringVar(&key, "k", os.Getenv("AUDIT_LOG_KEY"), "checkpoint key, default env AUDIT_LOG_KEY")
+	flag.Parse()
+
+	if filename == "" || key == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	report, err := logger.VerifyAuditFile(filename, []byte(key))
+	fmt.Printf("records %d, checkpoints %d, last seq %d, unsealed %d\n",
+		report.Records, report.Checkpoints, report.LastSeq, report.Unsealed)
+	if err != nil {
+		fmt.Printf("FAILED: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("OK")
+}
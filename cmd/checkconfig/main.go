@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"init-golang/libs/cli"
+	"init-golang/libs/config"
+	"os"
+)
+
+// checkconfig 按 JSON Schema 校验配置文件, 发现未知配置项, 类型错误以及取值范围错误
+//
+//	checkconfig etc/config.yaml etc/config.prod.yaml
+//	checkconfig -schema > config.schema.json
+func main() {
+	var printSchema bool
+	flag.BoolVar(&printSchema, "schema", false, "print the JSON Schema and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-schema] config.yaml...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if printSchema {
+		os.Stdout.Write(config.Schema())
+		return
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(cli.ExitUsage)
+	}
+
+	if !cli.CheckConfigFiles(os.Stdout, flag.Args()) {
+		os.Exit(cli.ExitConfig)
+	}
+}
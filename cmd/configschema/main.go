@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"init-golang/libs/config"
+	"log"
+	"os"
+)
+
+// configschema 根据 config.Config 生成 JSON Schema, 字段说明取自 -src 目录下结构体的注释
+//
+//	cd libs/config && go generate
+func main() {
+	var src, output string
+	flag.StringVar(&src, "src", ".", "source directory of package config")
+	flag.StringVar(&output, "o", "", "output file, default stdout")
+	flag.Parse()
+
+	comments, err := config.FieldComments(src)
+	if err != nil {
+		log.Fatalf("parse %s: %s", src, err)
+	}
+
+	data, err := json.MarshalIndent(config.GenerateSchema(comments), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	if output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
-	"flag"
+	"context"
+	"init-golang/libs/cli"
 	"init-golang/libs/config"
+	"init-golang/libs/flags"
+	"init-golang/libs/health"
 	"init-golang/libs/model"
-	"init-golang/src/example"
 	"log"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -19,59 +23,125 @@ import (
 )
 
 func main() {
-	// parse params from command line when start
-	flag.Parse()
-	config.Init()
-
-	// read config from yml files
-	conf := config.ReadConf()
+	app := cli.New("example")
+	app.Run = run
+	app.Models = []interface{}{&model.ExampleSettings{}}
+	app.Main()
+}
 
-	if conf.Name == "" {
-		log.Printf("invalid config name")
-		return
+// run 启动服务, 收到退出信号后返回
+func run(conf *config.Config) int {
+	if err := config.InitLog(conf.Name, conf.LoggerCfg); err != nil {
+		log.Printf("init logger failed: %s", err)
+		return cli.ExitConfig
 	}
-
-	config.InitLog(conf.Name, conf.LoggerCfg)
 	logger := config.DefaultLogger(conf.Name)
-
-	apiCfg := conf.MarketAPICfg
+	logger.Info("%s starting, %s", conf.Name, config.Build())
 
 	// connect mysql
-	mdb := &model.MarketDB{
-		UserName: conf.MySQLCfg.UserName,
-		Password: conf.MySQLCfg.Password,
-		Host:     conf.MySQLCfg.Host,
-		Port:     conf.MySQLCfg.Port,
-		DBName:   conf.MySQLCfg.DBName,
-	}
+	mdb := model.NewMarketDB(conf.MySQLCfg)
 	if ok := mdb.Connect(); !ok {
 		logger.Error("connect mysql database failed")
-		return
+		return cli.ExitUnavailable
 	}
 	logger.Info("connect database success %v", mdb.GetConnection())
 
 	// connect redis
-	mrds := &model.MarketRedis{
-		Addr:     conf.RedisCfg.Addr,
-		Database: conf.RedisCfg.Database,
-		Pwd:      conf.RedisCfg.Pwd,
-	}
+	mrds := model.NewMarketRedis(conf.RedisCfg)
 	if ok := mrds.ConnectRedis(); !ok {
 		logger.Error("connect redis database failed")
-		return
+		return cli.ExitUnavailable
 	}
 	logger.Info("connect redis success %v", mrds.GetRedisConnection())
 
-	mtx := config.NewMetrics("example", "", conf.MonitorCfg.ErrorDetails)
+	// 远程配置覆盖本地配置, 变更通过 OnChange 通知
+	remote, err := config.NewRemoteSource(conf.RemoteCfg, conf.Name, mdb.GetConnection(), mrds.GetRedisConnection(), conf.RedisCfg.PreFix)
+	if err != nil {
+		logger.Error("remote config: %s", err)
+		return cli.ExitConfig
+	}
+	if remote != nil {
+		if err := config.StartRemote(context.Background(), remote, conf.RemoteCfg.Interval); err != nil {
+			logger.Error("load remote config %s failed: %s", remote.Name(), err)
+			return cli.ExitUnavailable
+		}
+		conf = config.Current()
+		logger.Info("remote config %s loaded", remote.Name())
+	}
 
-	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		log.Println("err", http.ListenAndServe(conf.MonitorCfg.GetMonitorAddress(), nil))
-	}()
+	mtx := config.NewMetricsFromConfig("example", "", conf.MonitorCfg)
+	config.SetLogMetrics(conf.Name, mtx)
+
+	// 数据库查询计入 api_count 和 api_latency, 慢查询写入 sql.log
+	if err := mdb.Instrument(conf.Name, mtx, config.SQLLogger(conf.Name), conf.MySQLCfg.SlowQueryThreshold); err != nil {
+		logger.Error("instrument database failed: %s", err)
+	}
+	// 缓存命令计入 api_count 和 api_latency, 连接池状态上报到 service_status
+	if err := mrds.Instrument(conf.Name, mtx, config.APILogger(conf.Name)); err != nil {
+		logger.Error("instrument redis failed: %s", err)
+	}
+
+	// 功能开关: 配置文件, 以及 flags.provider 指定的 MySQL 或 Redis
+	flags.Default.SetMetrics(mtx)
+	if err := flags.Default.Setup(context.Background(), conf, mdb.GetConnection(), mrds.GetRedisConnection()); err != nil {
+		logger.Error("load feature flags failed: %s", err)
+		return cli.ExitUnavailable
+	}
+
+	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/admin/config", config.AuditHandler(config.DumpHandler()))
+	http.Handle("/admin/flags", config.AuditHandler(flags.Default.Handler()))
+
+	// 存活和就绪检查: 数据库, 缓存, 配置文件监听, 远程配置时效, 以及各服务注册的检查
+	health.Register(http.DefaultServeMux)
+	health.Add("mysql", health.MySQL(mdb.GetConnection()))
+	health.Add("redis", health.Redis(mrds.GetRedisConnection()))
+	// 文件监听停止后修改配置文件不再生效, 所有模式都检查
+	health.Add("config.watch", health.Fresh(config.WatchedAt, config.WatchWindow()))
+	if remote != nil {
+		health.Add("config", health.Fresh(config.RemoteSyncedAt, conf.RemoteCfg.FreshnessWindow()))
+		// 远程配置未通过校验时继续使用之前的配置, 只报告不影响就绪
+		health.AddOptional("config.apply", func(ctx context.Context) error {
+			return config.RemoteApplyError()
+		})
+	}
+
+	monitor := &monitorServer{}
+	if err := monitor.Serve(conf.MonitorCfg.GetMonitorAddress()); err != nil {
+		logger.Error("start monitor server failed: %s", err)
+	}
+
+	// 同一进程内运行 services 中的全部服务, 首次启动有服务失败时退出, 之后的失败在下次 Sync 时重试
+	services := newServiceGroup(mdb, mrds, mtx, health.Default)
+	if err := services.Sync(conf); err != nil {
+		logger.Error("%s", err)
 
-	strategy := example.NewStrategy()
-	strategy.Configure(conf.Name, apiCfg, logger, mdb, mrds, mtx)
-	strategy.Start()
+		services.Stop()
+		mdb.Close()
+		mrds.Close()
+
+		return cli.ExitFailure
+	}
+	http.Handle(cli.StatusPath, config.AuditHandler(cli.StatusHandler(services.Status)))
+
+	// 配置热更新: 监控地址, 错误详情, 远程配置时效和服务列表
+	config.OnChange(func(old, new *config.Config) {
+		mtx.SetErrorDetails(new.MonitorCfg.ErrorDetails)
+
+		if addr := new.MonitorCfg.GetMonitorAddress(); addr != old.MonitorCfg.GetMonitorAddress() {
+			logger.Warn("monitor address changed to %s", addr)
+			if err := monitor.Serve(addr); err != nil {
+				logger.Error("monitor address %s: %s, keep serving on %s", addr, err, old.MonitorCfg.GetMonitorAddress())
+			}
+		}
+		if remote != nil {
+			health.Add("config", health.Fresh(config.RemoteSyncedAt, new.RemoteCfg.FreshnessWindow()))
+		}
+
+		if err := services.Sync(new); err != nil {
+			logger.Error("%s, retry later", err)
+		}
+	})
 
 	// 读取 MySQL 数据
 	// var dbConfigs []activemarket.Cfg
@@ -98,25 +168,60 @@ func main() {
 		case sig := <-interruptSig:
 			logger.Warn("interrupt signal %d recv", sig)
 
-			strategy.Stop()
+			services.Stop()
 
 			mdb.Close()
 			mrds.Close()
 
-			os.Exit(0)
+			return cli.ExitOK
 		case sig := <-killSig:
 			logger.Warn("kill signal %d recv", sig)
 
-			strategy.Stop()
+			services.Stop()
 
 			mdb.Close()
 			mrds.Close()
 
-			os.Exit(0)
+			return cli.ExitOK
 		case <-configTimer.C:
-			logger.Debug("configs %v", apiCfg)
+			logger.Debug("configs %v", config.Current().MarketAPICfg)
 
-			strategy.Update()
+			services.Update()
+			if err := services.Sync(config.Current()); err != nil {
+				logger.Error("%s, retry later", err)
+			}
 		}
 	}
 }
+
+// monitorServer metrics, pprof 和健康检查服务, 地址变化时重启
+type monitorServer struct {
+	mu  sync.Mutex
+	srv *http.Server
+}
+
+// Serve 在 addr 上启动服务, 监听成功后再关闭之前的服务, 监听失败时保留之前的服务
+func (m *monitorServer) Serve(addr string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	if m.srv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		m.srv.Shutdown(ctx)
+		cancel()
+	}
+
+	srv := &http.Server{Addr: addr}
+	m.srv = srv
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Println("err", err)
+		}
+	}()
+	return nil
+}
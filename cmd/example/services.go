@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"init-golang/libs/cli"
+	"init-golang/libs/config"
+	"init-golang/libs/health"
+	"init-golang/libs/model"
+	"init-golang/src/example"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// serviceGroup 同一进程内的策略实例, 共享数据库, 缓存连接和监控, 各自独立启停
+type serviceGroup struct {
+	mu         sync.Mutex
+	mdb        *model.MarketDB
+	mrds       *model.MarketRedis
+	mtx        *config.Metrics
+	checker    *health.Checker
+	strategies map[string]*example.Strategy
+	failed     map[string]error // 启动失败, 等待下次 Sync 重试的服务及失败原因
+}
+
+func newServiceGroup(mdb *model.MarketDB, mrds *model.MarketRedis, mtx *config.Metrics, checker *health.Checker) *serviceGroup {
+	return &serviceGroup{
+		mdb:        mdb,
+		mrds:       mrds,
+		mtx:        mtx,
+		checker:    checker,
+		strategies: make(map[string]*example.Strategy),
+		failed:     make(map[string]error),
+	}
+}
+
+// Sync 启动配置中新增的服务, 停止被移除或禁用的服务, 返回本次启动失败的服务, 下次 Sync 时重试.
+// 运行中崩溃停止的服务同样重新启动.
+// 已启动服务的就绪检查注册到 checker, 启动失败的服务在 /readyz 中报告失败.
+func (g *serviceGroup) Sync(conf *config.Config) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	wanted := make(map[string]bool)
+	for _, id := range conf.ServiceIDs() {
+		wanted[id] = true
+	}
+
+	for id, strategy := range g.strategies {
+		if wanted[id] {
+			continue
+		}
+		strategy.Logger.Warn("service removed from config, stopping")
+		strategy.Stop()
+		delete(g.strategies, id)
+		g.checker.RemovePrefix(id + ".")
+	}
+	for id := range g.failed {
+		if !wanted[id] {
+			delete(g.failed, id)
+			g.checker.RemovePrefix(id + ".")
+		}
+	}
+
+	var failed []string
+	for id := range wanted {
+		strategy, ok := g.strategies[id]
+		if ok && strategy.Status() != example.STATUS_STOPPED {
+			continue
+		}
+
+		logger := config.DefaultLogger(id)
+		if !ok {
+			strategy = example.NewStrategy()
+			strategy.Configure(id, conf.MarketAPICfg, logger, g.mdb, g.mrds, g.mtx)
+		}
+		if !strategy.Start() {
+			logger.Error("start service failed")
+			err := strategy.Err()
+			if err == nil {
+				err = errors.New("start failed")
+			}
+			failed = append(failed, id)
+			delete(g.strategies, id)
+			g.failed[id] = err
+			g.checker.RemovePrefix(id + ".")
+			g.checker.Add(id+".status", func(ctx context.Context) error {
+				return err
+			})
+			continue
+		}
+		logger.Info("service started")
+		g.strategies[id] = strategy
+		delete(g.failed, id)
+
+		g.checker.RemovePrefix(id + ".")
+		for name, check := range strategy.Checks() {
+			g.checker.Add(id+"."+name, check)
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("start services %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Status 全部服务的状态和最近一次失败的原因, 包括启动失败和崩溃停止的服务, 全部服务已启动时 ready 为 true
+func (g *serviceGroup) Status() (map[string]cli.ServiceStatus, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	services := make(map[string]cli.ServiceStatus)
+	ready := len(g.strategies) > 0
+	for id, strategy := range g.strategies {
+		status := strategy.Status()
+		services[id] = serviceStatus(status.String(), strategy.Err())
+		if status != example.STATUS_STARTED {
+			ready = false
+		}
+	}
+	for id, err := range g.failed {
+		services[id] = serviceStatus("failed", err)
+		ready = false
+	}
+	return services, ready
+}
+
+func serviceStatus(state string, err error) cli.ServiceStatus {
+	status := cli.ServiceStatus{State: state}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// Update 重新读取各服务的数据库配置
+func (g *serviceGroup) Update() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, strategy := range g.strategies {
+		strategy.Update()
+	}
+}
+
+// Stop 停止全部服务
+func (g *serviceGroup) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for id, strategy := range g.strategies {
+		strategy.Stop()
+		delete(g.strategies, id)
+		g.checker.RemovePrefix(id + ".")
+	}
+}
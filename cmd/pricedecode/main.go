@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"init-golang/libs/logger"
+	"io"
+	"os"
+	"time"
+)
+
+// pricedecode 将二进制价格日志转换为 csv 或 json
+//
+//	pricedecode -o csv logs/example_price.bin logs/example_price.2022060110.bin > price.csv
+func main() {
+	var output, timeFormat string
+	flag.StringVar(&output, "o", "csv", "output format: csv|json")
+	flag.StringVar(&timeFormat, "t", time.RFC3339Nano, "time format")
+	flag.Parse()
+
+	if flag.NArg() == 0 || (output != "csv" && output != "json") {
+		fmt.Fprintf(os.Stderr, "usage: %s [-o csv|json] [-t layout] file...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	var write func(rec *logger.PriceRecord) error
+	if output == "csv" {
+		w := csv.NewWriter(out)
+		defer w.Flush()
+		w.Write([]string{"time", "symbol", "ask", "bid", "mid", "wmid", "weight"})
+		write = func(rec *logger.PriceRecord) error {
+			return w.Write([]string{
+				rec.Time.Format(timeFormat), rec.Symbol,
+				rec.Price[0].String(), rec.Price[1].String(), rec.Price[2].String(),
+				rec.Price[3].String(), rec.Price[4].String(),
+			})
+		}
+	} else {
+		enc := json.NewEncoder(out)
+		write = func(rec *logger.PriceRecord) error {
+			return enc.Encode(map[string]interface{}{
+				"time":   rec.Time.Format(timeFormat),
+				"symbol": rec.Symbol,
+				"ask":    rec.Price[0],
+				"bid":    rec.Price[1],
+				"mid":    rec.Price[2],
+				"wmid":   rec.Price[3],
+				"weight": rec.Price[4],
+			})
+		}
+	}
+
+	for _, filename := range flag.Args() {
+		if err := decode(filename, write); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func decode(filename string, write func(rec *logger.PriceRecord) error) error {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	decoder := logger.NewPriceDecoder(fd)
+	for {
+		rec, err := decoder.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := write(rec); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"init-golang/libs/config"
+	"os"
+	"strings"
+)
+
+// secretenc 加密配置中的密文, 从标准输入读取明文, 输出 enc: 值
+//
+//	CONFIG_SECRET_KEY=$(openssl rand -base64 32) secretenc < password.txt
+func main() {
+	plain, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && plain == "" {
+		fmt.Fprintf(os.Stderr, "read plaintext from stdin: %s\n", err)
+		os.Exit(2)
+	}
+
+	value, err := config.EncryptSecret(strings.TrimRight(plain, "\r\n"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(value)
+}
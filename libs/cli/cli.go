@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"init-golang/libs/config"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// 退出码
+const (
+	ExitOK          = 0 // 成功
+	ExitFailure     = 1 // 运行失败, 或检查结果不通过
+	ExitUsage       = 2 // 命令行参数错误
+	ExitConfig      = 3 // 配置文件不存在, 解析失败或校验失败
+	ExitUnavailable = 4 // 依赖不可用, 如数据库, 缓存连接失败或服务未运行
+)
+
+// Command 子命令
+type Command struct {
+	Name  string
+	Usage string // 参数说明, 如 `[-format yaml|json]`
+	Short string // 一行说明
+
+	// Flags 注册子命令自己的参数, -n -c -e -env-prefix 已经注册
+	Flags func(fs *flag.FlagSet)
+	// Run 执行子命令, 返回退出码, args 为参数解析后剩余的位置参数
+	Run func(args []string) int
+}
+
+// App 服务程序命令行, 内置 run, check-config, migrate, version, print-config, status 子命令.
+// 未指定子命令时执行 run.
+//
+//	example [-n name] [-c dir] [-e env] <command> [flags] [args]
+type App struct {
+	Name    string
+	Version string // 默认为编译时注入的 config.Version
+
+	// Run 读取配置后执行服务主逻辑, 返回退出码
+	Run func(conf *config.Config) int
+	// Models migrate 子命令 AutoMigrate 的表结构
+	Models []interface{}
+
+	Stdout io.Writer
+	Stderr io.Writer
+
+	commands map[string]*Command
+}
+
+// New 创建包含内置子命令的命令行
+func New(name string) *App {
+	app := &App{
+		Name:     name,
+		Version:  config.Version,
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		commands: make(map[string]*Command),
+	}
+	app.Add(app.runCommand())
+	app.Add(app.checkConfigCommand())
+	app.Add(app.migrateCommand())
+	app.Add(app.versionCommand())
+	app.Add(app.printConfigCommand())
+	app.Add(app.statusCommand())
+	return app
+}
+
+// Add 添加或替换子命令
+func (app *App) Add(cmd *Command) {
+	app.commands[cmd.Name] = cmd
+}
+
+// Main 执行命令行并以返回的退出码退出
+func (app *App) Main() {
+	os.Exit(app.Execute(os.Args[1:]))
+}
+
+// Execute 解析参数并执行子命令, 返回退出码
+func (app *App) Execute(args []string) int {
+	fs := flag.NewFlagSet(app.Name, flag.ContinueOnError)
+	fs.SetOutput(app.Stderr)
+	fs.Usage = func() { app.usage(fs) }
+	config.RegisterFlags(fs)
+	deprecated := registerDeprecatedFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	name, args := "run", fs.Args()
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if cmdArgs, ok := deprecated.command(); ok {
+		fmt.Fprintf(app.Stderr, "%s: --print-config, --print-env-keys and --format are deprecated, use '%s %s'\n",
+			app.Name, app.Name, strings.Join(cmdArgs, " "))
+		name, args = cmdArgs[0], cmdArgs[1:]
+	}
+
+	if name == "help" {
+		if len(args) == 0 {
+			fs.SetOutput(app.Stdout)
+			app.usage(fs)
+			return ExitOK
+		}
+		name, args = args[0], []string{"-h"}
+	}
+
+	cmd, ok := app.commands[name]
+	if !ok {
+		fmt.Fprintf(app.Stderr, "%s: unknown command %q\n", app.Name, name)
+		app.usage(fs)
+		return ExitUsage
+	}
+
+	sub := flag.NewFlagSet(app.Name+" "+cmd.Name, flag.ContinueOnError)
+	sub.SetOutput(app.Stderr)
+	sub.Usage = func() {
+		fmt.Fprintf(sub.Output(), "%s\n\nusage: %s %s %s\n\nflags:\n", cmd.Short, app.Name, cmd.Name, cmd.Usage)
+		sub.PrintDefaults()
+	}
+	config.RegisterFlags(sub)
+	if cmd.Flags != nil {
+		cmd.Flags(sub)
+	}
+	if err := sub.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	config.Init()
+
+	return cmd.Run(sub.Args())
+}
+
+// deprecatedFlags 子命令之前的旧参数, 转换为 print-config 子命令
+type deprecatedFlags struct {
+	printConfig  bool
+	printEnvKeys bool
+	format       string
+}
+
+func registerDeprecatedFlags(fs *flag.FlagSet) *deprecatedFlags {
+	d := &deprecatedFlags{}
+	fs.BoolVar(&d.printConfig, "print-config", false, "deprecated, use the print-config command")
+	fs.BoolVar(&d.printEnvKeys, "print-env-keys", false, "deprecated, use the print-config -env-keys command")
+	fs.StringVar(&d.format, "format", "yaml", "deprecated, use the print-config -format command")
+	return d
+}
+
+// command 指定了旧参数时返回对应的子命令和参数
+func (d *deprecatedFlags) command() ([]string, bool) {
+	if !d.printConfig && !d.printEnvKeys {
+		return nil, false
+	}
+	args := []string{"print-config", "-format", d.format}
+	if d.printEnvKeys {
+		args = append(args, "-env-keys")
+	}
+	return args, true
+}
+
+func (app *App) usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintf(w, "usage: %s [flags] <command> [command flags] [args]\n\ncommands:\n", app.Name)
+
+	names := make([]string, 0, len(app.commands))
+	for name := range app.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, app.commands[name].Short)
+	}
+
+	fmt.Fprintf(w, "\nflags, also accepted after the command:\n")
+	fs.PrintDefaults()
+
+	fmt.Fprintf(w, "\nexit codes:\n  %d ok\n  %d failure\n  %d usage error\n  %d invalid config\n  %d dependency unavailable\n",
+		ExitOK, ExitFailure, ExitUsage, ExitConfig, ExitUnavailable)
+	fmt.Fprintf(w, "\nrun '%s help <command>' for command flags, '%s' without command is '%s run'\n",
+		app.Name, app.Name, app.Name)
+}
+
+// readConf 读取配置, 失败时输出错误
+func (app *App) readConf() (*config.Config, bool) {
+	conf, err := config.ReadConf()
+	if err != nil {
+		fmt.Fprintf(app.Stderr, "invalid config: %s\n", strings.TrimSpace(err.Error()))
+		return nil, false
+	}
+	return conf, true
+}
@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"init-golang/libs/config"
+	"init-golang/libs/model"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StatusPath status 子命令请求的监控服务地址
+const StatusPath = "/admin/status"
+
+// ServiceStatus 服务状态和最近一次失败的原因
+type ServiceStatus struct {
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// StatusHandler 以 json 输出各服务状态, ready 为 false 时返回 503
+func StatusHandler(status func() (services map[string]ServiceStatus, ready bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		services, ready := status()
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(services)
+	})
+}
+
+// CheckConfigFiles 按 JSON Schema 逐个校验配置文件并输出结果, 全部通过时返回 true
+func CheckConfigFiles(w io.Writer, files []string) bool {
+	ok := true
+	for _, filename := range files {
+		errs, err := config.CheckConfigFile(filename)
+		if err != nil {
+			fmt.Fprintf(w, "%s: %s\n", filename, err)
+			ok = false
+			continue
+		}
+		if len(errs) > 0 {
+			fmt.Fprintf(w, "%s: %s\n", filename, errs)
+			ok = false
+			continue
+		}
+		fmt.Fprintf(w, "%s: OK\n", filename)
+	}
+	return ok
+}
+
+func (app *App) runCommand() *Command {
+	return &Command{
+		Name:  "run",
+		Short: "run the service",
+		Run: func(args []string) int {
+			if app.Run == nil {
+				fmt.Fprintf(app.Stderr, "%s: run is not supported\n", app.Name)
+				return ExitUsage
+			}
+			conf, ok := app.readConf()
+			if !ok {
+				return ExitConfig
+			}
+			return app.Run(conf)
+		},
+	}
+}
+
+func (app *App) checkConfigCommand() *Command {
+	return &Command{
+		Name:  "check-config",
+		Usage: "[config.yaml...]",
+		Short: "validate config files, default the layered config of -c and -e",
+		Run: func(args []string) int {
+			if len(args) > 0 {
+				if !CheckConfigFiles(app.Stdout, args) {
+					return ExitConfig
+				}
+				return ExitOK
+			}
+
+			_, err := config.ReadConf()
+			ok := CheckConfigFiles(app.Stdout, config.ConfigFiles())
+			if err != nil {
+				fmt.Fprintf(app.Stdout, "invalid config: %s\n", strings.TrimSpace(err.Error()))
+				return ExitConfig
+			}
+			if !ok {
+				return ExitConfig
+			}
+			fmt.Fprintln(app.Stdout, "OK")
+			return ExitOK
+		},
+	}
+}
+
+func (app *App) migrateCommand() *Command {
+	return &Command{
+		Name:  "migrate",
+		Short: "create or update the service tables in MySQL",
+		Run: func(args []string) int {
+			if len(app.Models) == 0 {
+				fmt.Fprintln(app.Stdout, "nothing to migrate")
+				return ExitOK
+			}
+
+			conf, ok := app.readConf()
+			if !ok {
+				return ExitConfig
+			}
+
+			mdb := model.NewMarketDB(conf.MySQLCfg)
+			if !mdb.Connect() {
+				fmt.Fprintln(app.Stderr, "connect mysql database failed")
+				return ExitUnavailable
+			}
+			defer mdb.Close()
+
+			db := mdb.GetConnection()
+			for _, m := range app.Models {
+				table := fmt.Sprintf("%T", m)
+				stmt := &gorm.Statement{DB: db}
+				if err := stmt.Parse(m); err == nil {
+					table = stmt.Schema.Table
+				}
+
+				if err := db.AutoMigrate(m); err != nil {
+					fmt.Fprintf(app.Stderr, "migrate %s failed: %s\n", table, err)
+					return ExitFailure
+				}
+				fmt.Fprintf(app.Stdout, "migrated %s\n", table)
+			}
+			return ExitOK
+		},
+	}
+}
+
+func (app *App) versionCommand() *Command {
+	return &Command{
+		Name:  "version",
+		Short: "print the version, git commit and build time",
+		Run: func(args []string) int {
+			build := config.Build()
+			fmt.Fprintf(app.Stdout, "%s %s\n", app.Name, app.Version)
+			fmt.Fprintf(app.Stdout, "commit:     %s\n", build.Commit)
+			fmt.Fprintf(app.Stdout, "build time: %s\n", build.BuildTime)
+			fmt.Fprintf(app.Stdout, "go version: %s\n", build.GoVersion)
+			return ExitOK
+		},
+	}
+}
+
+func (app *App) printConfigCommand() *Command {
+	var format string
+	var envKeys bool
+
+	return &Command{
+		Name:  "print-config",
+		Usage: "[-format yaml|json] [-env-keys]",
+		Short: "print the effective config with secrets masked",
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&format, "format", "yaml", "output format: yaml|json")
+			fs.BoolVar(&envKeys, "env-keys", false, "print supported config environment variables instead")
+		},
+		Run: func(args []string) int {
+			if envKeys {
+				config.PrintEnvKeys(app.Stdout)
+				return ExitOK
+			}
+
+			conf, ok := app.readConf()
+			if !ok {
+				return ExitConfig
+			}
+			if err := config.PrintConfig(app.Stdout, conf, format); err != nil {
+				fmt.Fprintf(app.Stderr, "print config failed: %s\n", err)
+				return ExitUsage
+			}
+			return ExitOK
+		},
+	}
+}
+
+func (app *App) statusCommand() *Command {
+	var addr string
+	var timeout time.Duration
+
+	return &Command{
+		Name:  "status",
+		Usage: "[-addr host:port] [-timeout 3s]",
+		Short: "query the status of a running service through its monitor address",
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&addr, "addr", "", "monitor address, default monitor in config")
+			fs.DurationVar(&timeout, "timeout", time.Second*3, "request timeout")
+		},
+		Run: func(args []string) int {
+			if addr == "" {
+				conf, ok := app.readConf()
+				if !ok {
+					return ExitConfig
+				}
+				addr = conf.MonitorCfg.GetMonitorAddress()
+			}
+			if host, port, err := net.SplitHostPort(addr); err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+				addr = net.JoinHostPort("127.0.0.1", port)
+			}
+
+			client := &http.Client{Timeout: timeout}
+			resp, err := client.Get("http://" + addr + StatusPath)
+			if err != nil {
+				fmt.Fprintf(app.Stderr, "%s is not running: %s\n", app.Name, err)
+				return ExitUnavailable
+			}
+			defer resp.Body.Close()
+
+			io.Copy(app.Stdout, resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				return ExitFailure
+			}
+			return ExitOK
+		},
+	}
+}
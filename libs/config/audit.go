@@ -0,0 +1,37 @@
+package config
+
+import (
+	"net/http"
+)
+
+// auditConfigChange 配置变更写入审计日志, 审计日志由 InitLog 打开, 未打开时不记录
+func auditConfigChange(diffs []string) {
+	if currentAuditLogger() == nil {
+		return
+	}
+
+	audit := AuditLogger("config")
+	for _, diff := range diffs {
+		audit.Info("changed %s", diff)
+	}
+}
+
+// AuditHandler 管理接口的请求写入审计日志, 包括来源地址, 请求和返回状态码
+func AuditHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		AuditLogger("admin").Info("%s %s from %s (%s), status %d", r.Method, r.URL.RequestURI(), r.RemoteAddr, r.UserAgent(), rec.status)
+	})
+}
+
+// statusRecorder 记录返回状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
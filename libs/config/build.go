@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// 编译信息, 由 build.sh 通过 -ldflags "-X init-golang/libs/config.Version=..." 注入
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// BuildInfo 编译信息
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Build 当前程序的编译信息, 未注入 commit 时取 go build 记录的 vcs 信息, 没有时为 unknown.
+// vcs 信息中的时间是提交时间, 不作为编译时间.
+func Build() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		modified := false
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && Commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+func (info BuildInfo) String() string {
+	return fmt.Sprintf("version %s, commit %s, built at %s, %s", info.Version, info.Commit, info.BuildTime, info.GoVersion)
+}
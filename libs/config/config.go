@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
@@ -20,17 +22,21 @@ var locations = []string{
 }
 
 var (
-	name     string
-	confPath string
-	env      string
+	name      = "name" // 配置文件未设置 name 时使用 -n, 默认 name
+	confPath  string
+	env       string
+	envPrefix = "APP"
 )
 
-func init() {
-	flag.StringVar(&name, "n", "name", "service name")
-	flag.StringVar(&confPath, "c", "configs", "config path")
-	flag.StringVar(&env, "e", "env", "environment name")
+// RegisterFlags 注册配置相关的命令行参数 -n -c -e -env-prefix, 默认值为当前值, 可以注册到多个 FlagSet
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&name, "n", name, "service name, name in config.yaml takes precedence")
+	fs.StringVar(&confPath, "c", confPath, "config directory, searched before the default locations")
+	fs.StringVar(&env, "e", env, "environment name, config.<env>.yaml is merged over config.yaml")
+	fs.StringVar(&envPrefix, "env-prefix", envPrefix, "prefix of config environment variables, e.g. APP_MYSQL__PASSWORD")
 }
 
+// Init 命令行参数解析后调用, 将 -c 加入配置文件查找路径
 func Init() {
 	if confPath != "" {
 		locations = append([]string{confPath}, locations...)
@@ -39,54 +45,119 @@ func Init() {
 
 // MySQL mysql 链接配置
 type MySQL struct {
-	UserName    string `mapstructure:"username" json:"username"`
-	Password    string `mapstructure:"password" json:"password"`
-	Host        string `mapstructure:"host" json:"host"`
-	Port        int32  `mapstructure:"port" json:"port"`
-	DBName      string `mapstructure:"dbname" json:"dbname"`
-	Connections string `mapstructure:"connections" json:"connections"`
+	UserName    string `mapstructure:"username" json:"username" validate:"required"`
+	Password    string `mapstructure:"password" json:"password" secret:"true"`
+	Host        string `mapstructure:"host" json:"host" validate:"required"`
+	Port        int32  `mapstructure:"port" json:"port" validate:"required,min=1,max=65535"`
+	DBName      string `mapstructure:"dbname" json:"dbname" validate:"required"`
+	Connections int    `mapstructure:"connections" json:"connections" validate:"min=0"` // 已废弃, 同 max_open_conns
+
+	MaxOpenConns    int               `mapstructure:"max_open_conns" json:"max_open_conns" validate:"min=0"`                                            // 最大连接数, 0 不限制
+	MaxIdleConns    int               `mapstructure:"max_idle_conns" json:"max_idle_conns" validate:"min=0"`                                            // 最大空闲连接数, 默认同最大连接数
+	ConnMaxLifetime time.Duration     `mapstructure:"conn_max_lifetime" json:"conn_max_lifetime" default:"3m"`                                          // 连接最长使用时间, 默认 3m
+	ConnMaxIdleTime time.Duration     `mapstructure:"conn_max_idle_time" json:"conn_max_idle_time"`                                                     // 连接最长空闲时间, 0 不限制
+	DialTimeout     time.Duration     `mapstructure:"dial_timeout" json:"dial_timeout"`                                                                 // 建立连接超时
+	ReadTimeout     time.Duration     `mapstructure:"read_timeout" json:"read_timeout"`                                                                 // 读超时
+	WriteTimeout    time.Duration     `mapstructure:"write_timeout" json:"write_timeout"`                                                               // 写超时
+	TLSMode         string            `mapstructure:"tls_mode" json:"tls_mode" validate:"oneof=disable preferred skip-verify verify" default:"disable"` // TLS 模式, 默认 disable
+	TLSCA           string            `mapstructure:"tls_ca" json:"tls_ca"`                                                                             // verify 模式下的 CA 证书文件, 为空使用系统证书
+	Charset         string            `mapstructure:"charset" json:"charset" default:"utf8"`                                                            // 字符集, 默认 utf8
+	Collation       string            `mapstructure:"collation" json:"collation"`                                                                       // 排序规则
+	Timezone        string            `mapstructure:"timezone" json:"timezone" default:"Local"`                                                         // 时区, 默认 Local
+	Params          map[string]string `mapstructure:"params" json:"params"`                                                                             // 其他 DSN 参数
+
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold" json:"slow_query_threshold" default:"200ms"` // 慢查询阈值, 超过时写入 sql.log, 0 不记录
 }
 
 // redis 链接配置
 type Redis struct {
-	Addr     string `mapstructure:"addr" json:"addr"`
-	Database int    `mapstructure:"database" json:"database"`
-	Pwd      string `mapstructure:"pwd" json:"pwd"`
-	PreFix   string `mapstructure:"prefix" json:"prefix"`
-}
+	Mode       string   `mapstructure:"mode" json:"mode" validate:"oneof=single sentinel cluster" default:"single"` // 部署模式 single:单节点 sentinel:哨兵 cluster:集群, 默认 single
+	Addr       string   `mapstructure:"addr" json:"addr" validate:"hostport"`                                       // 单节点地址
+	Addrs      []string `mapstructure:"addrs" json:"addrs"`                                                         // 哨兵或集群节点地址
+	MasterName string   `mapstructure:"master_name" json:"master_name"`                                             // 哨兵模式主节点名称
+	Database   int      `mapstructure:"database" json:"database" validate:"min=0,max=15"`
+	Username   string   `mapstructure:"username" json:"username"` // ACL 用户名
+	Pwd        string   `mapstructure:"pwd" json:"pwd" secret:"true"`
+	PreFix     string   `mapstructure:"prefix" json:"prefix"`
 
-// MarketURL 平台服务配置细节
-type MarketURL struct {
-	PubURL      string `mapstructure:"pub_url,omitempty" json:"pub_url,omitempty"`
-	IsPubCache  int    `mapstructure:"is_pub_cache,omitempty" json:"is_pub_cache,omitempty"`
-	PrivURL     string `mapstructure:"priv_url,omitempty" json:"priv_url,omitempty"`
-	IsPrivCache int    `mapstructure:"is_priv_cache,omitempty" json:"is_priv_cache,omitempty"`
+	SentinelPassword string `mapstructure:"sentinel_password" json:"sentinel_password" secret:"true"` // 哨兵节点密码
+
+	TLS           bool   `mapstructure:"tls" json:"tls"`                         // 是否使用 TLS
+	TLSSkipVerify bool   `mapstructure:"tls_skip_verify" json:"tls_skip_verify"` // 不校验服务端证书
+	TLSCA         string `mapstructure:"tls_ca" json:"tls_ca"`                   // CA 证书文件, 为空使用系统证书
+
+	PoolSize     int           `mapstructure:"pool_size" json:"pool_size" validate:"min=0"`           // 连接池大小, 默认 10 * CPU 数
+	MinIdleConns int           `mapstructure:"min_idle_conns" json:"min_idle_conns" validate:"min=0"` // 最少空闲连接数
+	PoolTimeout  time.Duration `mapstructure:"pool_timeout" json:"pool_timeout"`                      // 等待连接池超时
+	DialTimeout  time.Duration `mapstructure:"dial_timeout" json:"dial_timeout"`                      // 建立连接超时
+	ReadTimeout  time.Duration `mapstructure:"read_timeout" json:"read_timeout"`                      // 读超时
+	WriteTimeout time.Duration `mapstructure:"write_timeout" json:"write_timeout"`                    // 写超时
+	MaxRetries   int           `mapstructure:"max_retries" json:"max_retries" validate:"min=-1"`      // 最大重试次数, -1 不重试
 }
 
-// MarketAPI 平台服务配置
-type MarketAPI struct {
-	Spot    MarketURL `mapstructure:"spot" json:"spot"`
-	Futures MarketURL `mapstructure:"futures" json:"futures"`
-	Swap    MarketURL `mapstructure:"swap" json:"swap"`
-	Cache   MarketURL `mapstructure:"cache" json:"cache"`
-	Extra   MarketURL `mapstructure:"extra" json:"extra"`
+// validate 校验不同部署模式需要的配置项
+func (rds *Redis) validate(prefix string) (errs ValidationErrors) {
+	switch rds.Mode {
+	case "", "single":
+		if rds.Addr == "" && len(rds.Addrs) == 0 {
+			errs = append(errs, FieldError{Path: prefix + ".addr", Rule: "required", Message: "is required"})
+		}
+	case "sentinel":
+		if rds.MasterName == "" {
+			errs = append(errs, FieldError{Path: prefix + ".master_name", Rule: "required", Message: "is required in sentinel mode"})
+		}
+		fallthrough
+	case "cluster":
+		if len(rds.Addrs) == 0 {
+			errs = append(errs, FieldError{Path: prefix + ".addrs", Rule: "required", Message: "is required in " + rds.Mode + " mode"})
+		}
+	}
+
+	for i, addr := range rds.Addrs {
+		if msg := checkRule("hostport", reflect.ValueOf(addr)); msg != "" {
+			errs = append(errs, FieldError{Path: fmt.Sprintf("%s.addrs[%d]", prefix, i), Rule: "hostport", Message: msg})
+		}
+	}
+	return errs
 }
 
 // Logger 日志配置文件
 type Logger struct {
 	Path           string `mapstructure:"path" json:"path"`
-	FileRotateMode string `mapstructure:"file_rotate_mode" json:"file_rotate_mode"`
+	FileRotateMode string `mapstructure:"file_rotate_mode" json:"file_rotate_mode" validate:"oneof=minute hour day"`
 	TimeFormat     string `mapstructure:"time_format" json:"time_format"`
 	IsHideKey      bool   `mapstructure:"is_hide_key" json:"is_hide_key"`
 	IsColor        bool   `mapstructure:"is_color" json:"is_color"`
 	IsFieldsOrder  bool   `mapstructure:"is_fields_order" json:"is_fields_order"`
+	PriceFormat    string `mapstructure:"price_format" json:"price_format" validate:"oneof=text binary" default:"text"` // 价格日志格式 text:文本 binary:二进制
+
+	DiskSoftLimitMB   uint64        `mapstructure:"disk_soft_limit_mb" json:"disk_soft_limit_mb"`                 // 磁盘剩余空间低于此值(MB)时只写 Warn 及以上级别, 0 不检查
+	DiskHardLimitMB   uint64        `mapstructure:"disk_hard_limit_mb" json:"disk_hard_limit_mb"`                 // 磁盘剩余空间低于此值(MB)时暂停写日志文件, 0 不检查
+	DiskCheckInterval time.Duration `mapstructure:"disk_check_interval" json:"disk_check_interval" default:"10s"` // 磁盘空间检查周期, 默认 10s
+
+	AuditKey             string `mapstructure:"audit_key" json:"audit_key" secret:"true"`                            // 审计日志检查点签名密钥, 为空时读取环境变量 AUDIT_LOG_KEY, 都为空时启动失败
+	AuditCheckpointLines int64  `mapstructure:"audit_checkpoint_lines" json:"audit_checkpoint_lines" default:"1000"` // 审计日志每多少行写入一次检查点
 }
 
 type Monitor struct {
-	Address      string `mapstructure:"address" json:"address,omitempty"`
+	Address      string `mapstructure:"address" json:"address,omitempty" validate:"hostport"`
 	Host         string `mapstructure:"host" json:"host,omitempty"`
-	Port         int64  `mapstructure:"port" json:"port,omitempty"`
+	Port         int64  `mapstructure:"port" json:"port,omitempty" validate:"min=0,max=65535"`
 	ErrorDetails bool   `mapstructure:"error_details" json:"error_details,omitempty"`
+
+	LatencyBuckets []float64 `mapstructure:"latency_buckets" json:"latency_buckets,omitempty"`                       // api_latency_seconds 直方图分桶(秒), 默认 prometheus.DefBuckets, 重启生效
+	LatencySummary bool      `mapstructure:"latency_summary" json:"latency_summary" default:"true"`                  // 同时记录旧的 api_latency_ms summary, 看板迁移完成后关闭, 重启生效
+	MaxErrorLabels int       `mapstructure:"max_error_labels" json:"max_error_labels" default:"32" validate:"min=1"` // error_details 开启时 error 标签的种类上限, 超出归为 other, 重启生效
+}
+
+// validate 直方图分桶必须为正数且递增
+func (monitor *Monitor) validate(prefix string) (errs ValidationErrors) {
+	for i, bucket := range monitor.LatencyBuckets {
+		if bucket <= 0 || (i > 0 && bucket <= monitor.LatencyBuckets[i-1]) {
+			errs = append(errs, FieldError{Path: fmt.Sprintf("%s.latency_buckets[%d]", prefix, i), Rule: "increasing", Message: "buckets must be positive and increasing"})
+		}
+	}
+	return errs
 }
 
 func (monitor *Monitor) GetMonitorAddress() string {
@@ -102,22 +173,85 @@ func (monitor *Monitor) GetMonitorAddress() string {
 	return ":9090"
 }
 
+// Service 同一进程内运行的服务实例
+type Service struct {
+	ID       string `mapstructure:"id" json:"id"`             // 服务ID, 对应数据库中的服务配置, 同时作为日志前缀和监控 svc 标签
+	Disabled bool   `mapstructure:"disabled" json:"disabled"` // 停止该实例
+}
+
+// validateServices 服务ID不能为空且不能重复
+func validateServices(services []Service) (errs ValidationErrors) {
+	ids := make(map[string]bool)
+	for i, svc := range services {
+		path := fmt.Sprintf("services[%d].id", i)
+		if svc.ID == "" {
+			errs = append(errs, FieldError{Path: path, Rule: "required", Message: "is required"})
+			continue
+		}
+		if ids[svc.ID] {
+			errs = append(errs, FieldError{Path: path, Rule: "unique", Message: fmt.Sprintf("duplicate service %q", svc.ID)})
+		}
+		ids[svc.ID] = true
+	}
+	return errs
+}
+
 // Config 程序配置文件结构
 type Config struct {
-	Name         string    `mapstructure:"name" json:"name"`
+	Name         string    `mapstructure:"name" json:"name" validate:"required"`
+	Services     []Service `mapstructure:"services" json:"services"` // 服务实例, 为空时只运行 name
 	MySQLCfg     MySQL     `mapstructure:"mysql" json:"mysql"`
 	RedisCfg     Redis     `mapstructure:"redis" json:"redis"`
 	MarketAPICfg MarketAPI `mapstructure:"marketapi" json:"marketapi"`
 	LoggerCfg    Logger    `mapstructure:"logger" json:"logger"`
 	MonitorCfg   Monitor   `mapstructure:"monitor" json:"monitor"`
+	RemoteCfg    Remote    `mapstructure:"remote" json:"remote"`
+	FlagsCfg     Flags     `mapstructure:"flags" json:"flags"`
+
+	secrets map[string]bool // 带 secret 标签或使用了引用的配置项, 由 resolveSecrets 设置
+}
+
+// ServiceIDs 需要运行的服务ID, 未配置 services 时为 name
+func (conf *Config) ServiceIDs() []string {
+	if len(conf.Services) == 0 {
+		return []string{conf.Name}
+	}
+
+	ids := make([]string, 0, len(conf.Services))
+	for _, svc := range conf.Services {
+		if !svc.Disabled {
+			ids = append(ids, svc.ID)
+		}
+	}
+	return ids
 }
 
 func onConfigChange(in fsnotify.Event) {
 	log.Println("Config file changed:", in.Name)
+
+	if err := Reload(); err != nil {
+		log.Printf("config reload failed, keep the current config: %s", err)
+	}
 }
 
-// ReadConf 读取配置文件
-func ReadConf() *Config {
+// parseConf 解析并校验 viper 中的配置
+func parseConf() (*Config, error) {
+	conf := Config{Name: name}
+	setDefaults(&conf)
+	if err := viper.Unmarshal(&conf); err != nil {
+		return nil, err
+	}
+	if err := resolveSecrets(&conf); err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// ReadConf 读取配置文件, 文件不存在, 解析失败或校验失败时返回错误
+func ReadConf() (*Config, error) {
 	// 配置文件名称, 默认扩展名
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -133,28 +267,30 @@ func ReadConf() *Config {
 	}
 
 	// 读取配置文件
-	err := viper.ReadInConfig()
+	err := readConfigFiles()
 	if err != nil {
 		// 配置文件读取出错
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			// 文件找不存在
-			log.Printf("文件不存在")
-		} else {
-			// 文件存在, 其他错误
-			log.Printf("文件存在, 其他错误: %s", err)
+			return nil, fmt.Errorf("config file not found: %s", err)
 		}
-
-		panic(fmt.Errorf("fatal error config file: %s", err))
+		// 文件存在, 其他错误
+		return nil, fmt.Errorf("read config file: %s", err)
 	}
 
-	conf := Config{Name: name}
-	err = viper.Unmarshal(&conf)
+	conf, err := parseConf()
 	if err != nil {
-		panic(fmt.Errorf("fatal error parse config file: %s", err))
+		return nil, fmt.Errorf("parse config file %s: %w", viper.ConfigFileUsed(), err)
 	}
+	setCurrent(conf)
+	logKeySources()
 
-	viper.WatchConfig()
-	viper.OnConfigChange(onConfigChange)
+	// 日志配置热更新
+	OnChange(reloadLog)
+
+	if err := watchConfig(); err != nil {
+		log.Printf("watch config files failed: %s", err)
+	}
 
-	return &conf
+	return conf, nil
 }
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PrintConfig 按 format 输出生效的配置, yaml 或 json
+func PrintConfig(w io.Writer, conf *Config, format string) error {
+	data, err := Dump(conf, format)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// effectiveConfig 生效的配置, 包括配置来源
+type effectiveConfig struct {
+	Env     string                 `json:"env" yaml:"env"`
+	Files   []string               `json:"files" yaml:"files"`
+	Sources map[string]string      `json:"sources" yaml:"sources"`
+	Config  map[string]interface{} `json:"config" yaml:"config"`
+}
+
+// Dump 输出生效的配置, format 为 yaml 或 json, 密文配置和地址中的密码被替换为 SecretMask.
+// files 为合并的配置文件, sources 为每个配置项的来源文件或环境变量.
+func Dump(conf *Config, format string) ([]byte, error) {
+	out := effectiveConfig{
+		Env:     env,
+		Files:   ConfigFiles(),
+		Sources: KeySources(),
+		Config:  make(map[string]interface{}),
+	}
+
+	walkConfig(reflect.ValueOf(conf).Elem(), "", func(key string, _ reflect.StructField, value reflect.Value) {
+		setNested(out.Config, strings.Split(key, "."), maskedValue(conf.secrets, key, value))
+	})
+
+	switch format {
+	case "", "yaml":
+		return yaml.Marshal(out)
+	case "json":
+		return json.MarshalIndent(out, "", "  ")
+	}
+	return nil, fmt.Errorf("unknown format %q, yaml or json", format)
+}
+
+// maskedValue 用于输出的配置值, 密文替换为 SecretMask, 地址隐藏密码, 递归处理 struct, slice 和 map
+func maskedValue(secrets map[string]bool, key string, value reflect.Value) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	if secrets[key] {
+		if value.IsZero() {
+			return ""
+		}
+		return SecretMask
+	}
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(value.Int()).String()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return maskURL(value.String())
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return maskedValue(secrets, key, value.Elem())
+	case reflect.Struct:
+		fields := make(map[string]interface{})
+		walkConfig(value, "", func(sub string, _ reflect.StructField, v reflect.Value) {
+			setNested(fields, strings.Split(sub, "."), maskedValue(secrets, key+"."+sub, v))
+		})
+		return fields
+	case reflect.Slice:
+		if value.IsNil() {
+			return value.Interface()
+		}
+		items := make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = maskedValue(secrets, fmt.Sprintf("%s[%d]", key, i), value.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if value.IsNil() {
+			return value.Interface()
+		}
+		entries := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			name := fmt.Sprint(iter.Key().Interface())
+			entries[name] = maskedValue(secrets, key+"."+name, iter.Value())
+		}
+		return entries
+	}
+	return value.Interface()
+}
+
+func setNested(m map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := m[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[key] = child
+		}
+		m = child
+	}
+	m[path[len(path)-1]] = value
+}
+
+// DumpHandler 输出当前生效的配置, 通过 ?format=json 指定格式, 默认 yaml
+func DumpHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := Current()
+		if conf == nil {
+			http.Error(w, "config not loaded", http.StatusServiceUnavailable)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		data, err := Dump(conf, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "application/yaml")
+		}
+		w.Write(data)
+	})
+}
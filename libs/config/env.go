@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+// EnvName 配置项对应的环境变量名, 层级用双下划线分隔, 如 marketapi.spot.pub_url -> APP_MARKETAPI__SPOT__PUB_URL
+func EnvName(key string) string {
+	name := strings.ToUpper(strings.ReplaceAll(key, ".", "__"))
+	if envPrefix == "" {
+		return name
+	}
+	return envPrefix + "_" + name
+}
+
+// applyEnvOverrides 环境变量覆盖配置文件中的值, 记录来源为 env:<NAME>
+func applyEnvOverrides(sources map[string]string) error {
+	var err error
+	walkConfig(reflect.ValueOf(Config{}), "", func(key string, field reflect.StructField, _ reflect.Value) {
+		name := EnvName(key)
+		value, ok := os.LookupEnv(name)
+		if !ok || err != nil {
+			return
+		}
+
+		v, e := envValue(field.Type, value)
+		if e != nil {
+			err = fmt.Errorf("env %s: %s", name, e)
+			return
+		}
+		viper.Set(key, v)
+		sources[key] = "env:" + name
+	})
+	return err
+}
+
+// envValue 环境变量和远程配置的字符串转换为配置值, 列表和映射使用 JSON, 如 [{"id":"a"}], {"timeout":"5s"},
+// 字符串和数字列表也可以用逗号分隔
+func envValue(t reflect.Type, value string) (interface{}, error) {
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Map {
+		return value, nil
+	}
+
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var v interface{}
+		if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %s", err)
+		}
+		return v, nil
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Struct {
+		return value, nil
+	}
+	return nil, fmt.Errorf("%s requires a JSON value", t)
+}
+
+// envFormat 环境变量的取值格式
+func envFormat(t reflect.Type) string {
+	switch {
+	case t.Kind() == reflect.Map, t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct:
+		return "json"
+	case t.Kind() == reflect.Slice:
+		return "json or comma separated"
+	}
+	return ""
+}
+
+// PrintEnvKeys 输出全部支持的环境变量
+func PrintEnvKeys(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	fmt.Fprintln(tw, "ENV\tKEY\tTYPE\tFORMAT")
+	walkConfig(reflect.ValueOf(Config{}), "", func(key string, field reflect.StructField, _ reflect.Value) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", EnvName(key), key, field.Type, envFormat(field.Type))
+	})
+}
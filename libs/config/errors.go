@@ -0,0 +1,205 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"init-golang/libs/logger"
+	"io"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ErrorOther 未分类错误, 以及超出标签数量上限的错误使用的标签
+const ErrorOther = "other"
+
+// defaultMaxErrorLabels 错误标签数量上限的默认值, 不含 other
+const defaultMaxErrorLabels = 32
+
+// maxUnclassifiedLogs 未分类错误类型最多输出日志的种类数
+const maxUnclassifiedLogs = 100
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ErrorRule 错误分类规则, 匹配时返回标签
+type ErrorRule func(err error) (label string, ok bool)
+
+// ErrorClassifier 错误分类表, 把错误映射到固定的 metrics 标签, 避免错误信息中的 ID 等造成标签值过多.
+// 规则按注册顺序匹配, 未匹配的错误为 other, 不同标签的数量超过上限后新出现的标签也归为 other.
+type ErrorClassifier struct {
+	mu           sync.RWMutex
+	base         *ErrorClassifier // 先匹配 base 的规则, 之后注册到 base 的规则同样生效
+	rules        []ErrorRule
+	labels       map[string]bool
+	maxLabels    int
+	unclassified map[string]bool
+}
+
+// NewErrorClassifier 创建空的错误分类表
+func NewErrorClassifier() *ErrorClassifier {
+	return &ErrorClassifier{
+		labels:       make(map[string]bool),
+		maxLabels:    defaultMaxErrorLabels,
+		unclassified: make(map[string]bool),
+	}
+}
+
+// DefaultErrors 默认错误分类表, 已注册常见的超时, 取消, 未找到和网络错误
+var DefaultErrors = NewErrorClassifier()
+
+func init() {
+	DefaultErrors.Register(context.DeadlineExceeded, "timeout")
+	DefaultErrors.Register(os.ErrDeadlineExceeded, "timeout")
+	DefaultErrors.Register(context.Canceled, "canceled")
+	DefaultErrors.Register(gorm.ErrRecordNotFound, "not_found")
+	DefaultErrors.Register(sql.ErrNoRows, "not_found")
+	DefaultErrors.Register(redis.Nil, "not_found")
+	DefaultErrors.Register(io.EOF, "eof")
+	DefaultErrors.Register(io.ErrUnexpectedEOF, "eof")
+	DefaultErrors.RegisterType((*net.Error)(nil), "network")
+	DefaultErrors.RegisterType(ValidationErrors(nil), "validation")
+}
+
+// Limit 使用当前规则, 单独统计标签数量的分类表, 上限小于等于 0 时使用默认值 32.
+// 每个 Metrics 使用自己的分类表, 上限互不影响.
+func (c *ErrorClassifier) Limit(max int) *ErrorClassifier {
+	limited := NewErrorClassifier()
+	limited.base = c
+	limited.SetMaxLabels(max)
+	return limited
+}
+
+// SetMaxLabels 设置不同标签的数量上限, 小于等于 0 时使用默认值 32
+func (c *ErrorClassifier) SetMaxLabels(max int) {
+	if max <= 0 {
+		max = defaultMaxErrorLabels
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxLabels = max
+}
+
+// Register 哨兵错误, 通过 errors.Is 匹配错误链
+func (c *ErrorClassifier) Register(target error, label string) {
+	c.RegisterRule(func(err error) (string, bool) {
+		return label, errors.Is(err, target)
+	})
+}
+
+// RegisterType 错误类型, 通过 errors.As 匹配错误链, 包括 Unwrap() []error 合并的错误.
+// 接口类型以指针形式传入, 如 (*net.Error)(nil).
+func (c *ErrorClassifier) RegisterType(sample interface{}, label string) {
+	typ := reflect.TypeOf(sample)
+	if typ == nil {
+		panic("config: RegisterType of nil")
+	}
+	if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface {
+		typ = typ.Elem()
+	} else if !typ.Implements(errorType) {
+		panic("config: RegisterType of " + typ.String() + " which does not implement error")
+	}
+
+	c.RegisterRule(func(err error) (string, bool) {
+		return label, errors.As(err, reflect.New(typ).Interface())
+	})
+}
+
+// RegisterRule 自定义规则, 如按 HTTP 状态码分类, 规则返回的标签应当是有限的
+func (c *ErrorClassifier) RegisterRule(rule ErrorRule) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rules = append(c.rules, rule)
+}
+
+// Classify 错误标签, 未匹配或超出标签数量上限时返回 other 和 false
+func (c *ErrorClassifier) Classify(err error) (string, bool) {
+	if err == nil {
+		return "", true
+	}
+
+	for _, rule := range c.ruleList() {
+		label, ok := rule(err)
+		if !ok {
+			continue
+		}
+		if c.admit(label) {
+			return label, true
+		}
+		c.logUnclassified(err, "label "+label+" exceeds the limit")
+		return ErrorOther, false
+	}
+
+	c.logUnclassified(err, "no matching rule")
+	return ErrorOther, false
+}
+
+// ruleList base 的规则和自己的规则, 按注册顺序
+func (c *ErrorClassifier) ruleList() []ErrorRule {
+	var rules []ErrorRule
+	if c.base != nil {
+		rules = c.base.ruleList()
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append(rules, c.rules...)
+}
+
+// admit 标签已经出现过, 或未达到数量上限
+func (c *ErrorClassifier) admit(label string) bool {
+	c.mu.RLock()
+	seen, full := c.labels[label], len(c.labels) >= c.maxLabels
+	c.mu.RUnlock()
+	if seen {
+		return true
+	}
+	if full {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.labels) >= c.maxLabels && !c.labels[label] {
+		return false
+	}
+	c.labels[label] = true
+	return true
+}
+
+// logUnclassified 每种错误类型只输出一次, 便于补充分类规则
+func (c *ErrorClassifier) logUnclassified(err error, reason string) {
+	typeName := logger.ErrorTypeName(logger.RootCause(err))
+
+	c.mu.Lock()
+	if c.unclassified[typeName] || len(c.unclassified) >= maxUnclassifiedLogs {
+		c.mu.Unlock()
+		return
+	}
+	c.unclassified[typeName] = true
+	c.mu.Unlock()
+
+	log.Printf("unclassified error %s (%s): %s", typeName, reason, err)
+}
+
+// RegisterError 在默认错误分类表中注册哨兵错误
+func RegisterError(target error, label string) {
+	DefaultErrors.Register(target, label)
+}
+
+// RegisterErrorType 在默认错误分类表中注册错误类型
+func RegisterErrorType(sample interface{}, label string) {
+	DefaultErrors.RegisterType(sample, label)
+}
+
+// RegisterErrorRule 在默认错误分类表中注册自定义规则
+func RegisterErrorRule(rule ErrorRule) {
+	DefaultErrors.RegisterRule(rule)
+}
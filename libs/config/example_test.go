@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// TestExampleConfig 仓库中的 etc/config_example.yaml 使用默认命令行参数可以通过解析和校验
+func TestExampleConfig(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigFile("../../etc/config_example.yaml")
+	if err := readConfigFiles(); err != nil {
+		t.Fatalf("read example config: %s", err)
+	}
+	conf, err := parseConf()
+	if err != nil {
+		t.Fatalf("parse example config: %s", err)
+	}
+	if conf.Name == "" {
+		t.Errorf("name is empty")
+	}
+}
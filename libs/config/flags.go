@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// FeatureFlag 功能开关, 同名开关按 服务+交易对, 服务, 交易对, 全部 的顺序匹配
+type FeatureFlag struct {
+	Name    string `mapstructure:"name" json:"name"`                                          // 开关名称
+	Service string `mapstructure:"service" json:"service"`                                    // 服务ID, 为空对全部服务生效
+	Symbol  string `mapstructure:"symbol" json:"symbol"`                                      // 交易对, 为空对全部交易对生效
+	Enabled bool   `mapstructure:"enabled" json:"enabled"`                                    // 是否开启
+	Percent *int   `mapstructure:"percent" json:"percent,omitempty" validate:"min=0,max=100"` // 灰度比例, 按 服务+交易对 分桶, 未配置为全量, 0 为关闭
+}
+
+// Flags 功能开关配置
+type Flags struct {
+	Provider string        `mapstructure:"provider" json:"provider" validate:"oneof=mysql redis"` // 额外从 mysql 或 redis 读取开关, 优先于配置文件, 为空不启用
+	Table    string        `mapstructure:"table" json:"table" default:"feature_flag"`             // MySQL 表名
+	Key      string        `mapstructure:"key" json:"key"`                                        // Redis hash 键名, 默认 <redis.prefix>flags
+	Interval time.Duration `mapstructure:"interval" json:"interval" default:"10s"`                // 拉取周期
+
+	Items []FeatureFlag `mapstructure:"items" json:"items"` // 配置文件中的开关
+}
+
+// validateFlags 开关名称不能为空, 同一作用范围不能重复, 比例在 0-100 之间
+func validateFlags(flags []FeatureFlag) (errs ValidationErrors) {
+	scopes := make(map[string]bool)
+	for i, flag := range flags {
+		path := fmt.Sprintf("flags.items[%d]", i)
+		if flag.Name == "" {
+			errs = append(errs, FieldError{Path: path + ".name", Rule: "required", Message: "is required"})
+			continue
+		}
+		if flag.Percent != nil && (*flag.Percent < 0 || *flag.Percent > 100) {
+			errs = append(errs, FieldError{Path: path + ".percent", Rule: "max=100", Message: "must be between 0 and 100"})
+		}
+
+		scope := flag.Name + "|" + flag.Service + "|" + flag.Symbol
+		if scopes[scope] {
+			errs = append(errs, FieldError{Path: path, Rule: "unique", Message: fmt.Sprintf("duplicate flag %q for service %q symbol %q", flag.Name, flag.Service, flag.Symbol)})
+		}
+		scopes[scope] = true
+	}
+	return errs
+}
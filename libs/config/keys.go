@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagName 配置项名称, 取 mapstructure 标签逗号前部分
+func tagName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// walkConfig 遍历配置结构体的叶子字段, key 为 yaml 中的路径, 如 marketapi.spot.pub_url
+func walkConfig(v reflect.Value, prefix string, f func(key string, field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("mapstructure") == "-" {
+			continue
+		}
+
+		key := tagName(field)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		value := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			walkConfig(value, key, f)
+			continue
+		}
+		f(key, field, value)
+	}
+}
+
+// setDefaults 按 default 标签设置默认值, 在解析配置前调用, 配置中出现的值覆盖默认值.
+// 与 JSON Schema 中的 default 一致, 省略的配置项按 schema 描述的默认值生效.
+func setDefaults(conf *Config) {
+	walkConfig(reflect.ValueOf(conf).Elem(), "", func(key string, field reflect.StructField, value reflect.Value) {
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			return
+		}
+		if err := setValue(value, def); err != nil {
+			log.Printf("config %s: invalid default %q: %s", key, def, err)
+		}
+	})
+}
+
+// setValue 字符串转换为字段类型并赋值
+func setValue(value reflect.Value, s string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+// ConfigKeys 全部配置项路径
+func ConfigKeys() []string {
+	var keys []string
+	walkConfig(reflect.ValueOf(Config{}), "", func(key string, _ reflect.StructField, _ reflect.Value) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// flattenConfig 配置转换为 路径 -> 值
+func flattenConfig(conf *Config) map[string]interface{} {
+	values := make(map[string]interface{})
+	walkConfig(reflect.ValueOf(conf).Elem(), "", func(key string, _ reflect.StructField, value reflect.Value) {
+		values[key] = value.Interface()
+	})
+	return values
+}
+
+// DiffConfig 对比配置, 返回发生变化的配置项, 格式为 `key: old -> new`, 密文配置的值和地址中的密码被替换为 SecretMask, 包括 map 和 slice 中的元素
+func DiffConfig(old, new *Config) []string {
+	if old == nil || new == nil {
+		return nil
+	}
+
+	olds := flattenConfig(old)
+	news := flattenConfig(new)
+
+	var diffs []string
+	for key, value := range news {
+		if reflect.DeepEqual(olds[key], value) {
+			continue
+		}
+		if old.secrets[key] || new.secrets[key] {
+			diffs = append(diffs, fmt.Sprintf("%s: %s -> %s", key, SecretMask, SecretMask))
+		} else {
+			diffs = append(diffs, fmt.Sprintf("%s: %v -> %v", key,
+				maskedValue(old.secrets, key, reflect.ValueOf(olds[key])), maskedValue(new.secrets, key, reflect.ValueOf(value))))
+		}
+	}
+	sort.Strings(diffs)
+
+	return diffs
+}
@@ -3,10 +3,12 @@ package config
 import (
 	"fmt"
 	"init-golang/libs/logger"
-	"io"
+	"init-golang/libs/utils"
+	"log"
 	"os"
 	"path"
 	"sync"
+	"time"
 )
 
 var (
@@ -17,6 +19,10 @@ var (
 	apiLogger *logger.Logger
 	// priceLogger 价格日志
 	priceLogger *logger.Logger
+	// auditLogger 审计日志
+	auditLogger *logger.Logger
+	// sqlLogger 慢查询日志
+	sqlLogger *logger.Logger
 )
 
 var (
@@ -37,6 +43,10 @@ var (
 	knockCFLogger LoggerMap
 	// PriceCFLogger 价格日志
 	priceCFLogger LoggerMap
+	// auditCFLogger 审计日志
+	auditCFLogger LoggerMap
+	// sqlCFLogger 慢查询日志
+	sqlCFLogger LoggerMap
 )
 
 // LoggerMap 日志多例
@@ -223,6 +233,16 @@ func (cf *CFLogger) Panic(format string, args ...interface{}) {
 	}
 }
 
+// Price 记录对标价格, 价格日志为二进制格式时只保留 symbol 和 price
+func (cf *CFLogger) Price(symbol string, price utils.BenchmarkPriceItem) {
+	if cf.IsInfoEnabled() {
+		cf.Logger.WithFields(logger.Fields{
+			logger.FieldKeySymbol: symbol,
+			logger.FieldKeyPrice:  price,
+		}).Info(cf.Prefix)
+	}
+}
+
 // SetLevel 设置日志等级
 func (cf *CFLogger) SetLevel(level int16) {
 	cf.Level = level
@@ -279,11 +299,57 @@ func PriceLogger(key string) *CFLogger {
 	return cfLogger
 }
 
+// AuditLogger 审计日志, 下单和配置变更等需要防篡改的记录.
+// 审计日志由 InitLog 打开, 未调用 InitLog 且无法打开时 panic, 审计记录不能丢弃或脱离哈希链.
+func AuditLogger(key string) *CFLogger {
+	cfLogger, ok := auditCFLogger.Read(key)
+	if err := initAuditLogger(); err != nil {
+		panic(err)
+	}
+	if cfLogger == nil || !ok {
+		cfLogger = &CFLogger{
+			Level:  int16(logger.TraceLevel),
+			Prefix: key,
+			Logger: currentAuditLogger(),
+		}
+		auditCFLogger.Store(key, cfLogger)
+	}
+	return cfLogger
+}
+
+// SQLLogger 数据库慢查询日志
+func SQLLogger(key string) *CFLogger {
+	cfLogger, ok := sqlCFLogger.Read(key)
+	if sqlLogger == nil {
+		initSQLLogger()
+	}
+	if cfLogger == nil || !ok {
+		cfLogger = &CFLogger{
+			Level:  int16(logger.TraceLevel),
+			Prefix: key,
+			Logger: sqlLogger,
+		}
+		sqlCFLogger.Store(key, cfLogger)
+	}
+	return cfLogger
+}
+
+var loggerMu sync.Mutex // 保护 loggerMaps, loggerFormatter 和 auditLogger, 配置热加载时与创建日志并发
 var loggerMaps map[string]*logger.Logger = make(map[string]*logger.Logger)
 var loggerFormatter = logger.FormatterNginx{}
 var loggerFilePath = "."  // 默认当前文件夹
 var loggerRotateMode = "" // hour:小时分割 day:天分割 "":不分割
 var namePrefix = ""       // 日志文件名前缀
+var priceFormat = ""      // text:文本 binary:二进制
+var diskSoftLimit uint64  // 磁盘剩余空间低于此值时只写 Warn 及以上级别
+var diskHardLimit uint64  // 磁盘剩余空间低于此值时暂停写文件
+var diskCheckInterval time.Duration
+
+var logMetrics *Metrics
+var logMetricsSvc string
+var logWriters sync.Map // filename -> *logger.WriterFile
+var auditKey = ""       // 审计日志签名密钥
+var auditCheckpointLines int64
 
 // GetMMLogger 基于交易对存储日志工厂方法
 func GetMMLogger(symbol string) *logger.Logger {
@@ -291,12 +357,15 @@ func GetMMLogger(symbol string) *logger.Logger {
 		symbol = "default"
 	}
 
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
 	ins, ok := loggerMaps[symbol]
 	if !ok {
-		// TODO 此处加锁, 或者使用 sync map
 		ins = logger.New()
-		ins.SetFormatter(&loggerFormatter)
-		ins.SetOutput(io.MultiWriter(ins.NewLogFile(&logger.WriterFile{Filename: loggerFilePath + "/" + symbol + ".log", RotateMode: loggerRotateMode})))
+		formatter := loggerFormatter
+		ins.SetFormatter(&formatter)
+		ins.NewLogWriter(newWriterFile(loggerFilePath + "/" + symbol + ".log"))
 		ins.SetLevel(logger.TraceLevel)
 
 		loggerMaps[symbol] = ins
@@ -304,17 +373,28 @@ func GetMMLogger(symbol string) *logger.Logger {
 	return ins
 }
 
-// InitLog 初始化日志配置
+// InitLog 初始化日志配置并打开审计日志, 审计密钥为空或审计日志无法续写时返回错误
 func InitLog(prefix string, loggerCfg Logger) error {
 
 	// logger.SetFormatter(&logger.TextFormatter{DisableTimestamp: true})
 	// logger.SetFormatter(&logger.FormatterJSON{})
+	loggerMu.Lock()
 	loggerFormatter = logger.FormatterNginx{
 		HideKeys:        loggerCfg.IsHideKey,
-		NoColors:        loggerCfg.IsColor,
+		NoColors:        !loggerCfg.IsColor,
 		TimestampFormat: loggerCfg.TimeFormat,
 	}
+	loggerMu.Unlock()
 	loggerRotateMode = loggerCfg.FileRotateMode
+	priceFormat = loggerCfg.PriceFormat
+	diskSoftLimit = loggerCfg.DiskSoftLimitMB << 20
+	diskHardLimit = loggerCfg.DiskHardLimitMB << 20
+	diskCheckInterval = loggerCfg.DiskCheckInterval
+	auditKey = loggerCfg.AuditKey
+	if auditKey == "" {
+		auditKey = os.Getenv("AUDIT_LOG_KEY")
+	}
+	auditCheckpointLines = loggerCfg.AuditCheckpointLines
 
 	runpath, _ := os.Getwd()
 	if path.IsAbs(loggerCfg.Path) {
@@ -331,28 +411,149 @@ func InitLog(prefix string, loggerCfg Logger) error {
 		namePrefix = ""
 	}
 
-	return nil
+	// 审计日志缺少密钥或无法续写时启动失败
+	return initAuditLogger()
+}
+
+// SetLogMetrics 日志文件磁盘状态上报到 metrics, type 为 log_disk_state, 值为 logger.DiskState
+func SetLogMetrics(svc string, mtx *Metrics) {
+	logMetricsSvc = svc
+	logMetrics = mtx
+
+	logWriters.Range(func(k, v interface{}) bool {
+		w := v.(*logger.WriterFile)
+		reportLogDisk(w, w.DiskState(), 0)
+		return true
+	})
+}
+
+func reportLogDisk(w *logger.WriterFile, state logger.DiskState, free uint64) {
+	if logMetrics != nil {
+		logMetrics.SetSvcValue(logMetricsSvc, path.Base(w.Filename), "log_disk_state", float64(state))
+	}
+}
+
+func newWriterFile(filename string) *logger.WriterFile {
+	w := &logger.WriterFile{
+		Filename:          filename,
+		RotateMode:        loggerRotateMode,
+		DiskSoftLimit:     diskSoftLimit,
+		DiskHardLimit:     diskHardLimit,
+		DiskCheckInterval: diskCheckInterval,
+		OnDiskState:       reportLogDisk,
+	}
+	logWriters.Store(filename, w)
+	return w
+}
+
+// reloadLog 配置变更时更新日志格式, 日志路径和分割方式需要重启生效
+func reloadLog(old, new *Config) {
+	oldCfg, newCfg := old.LoggerCfg, new.LoggerCfg
+	if oldCfg.Path != newCfg.Path || oldCfg.FileRotateMode != newCfg.FileRotateMode || oldCfg.PriceFormat != newCfg.PriceFormat {
+		log.Printf("logger path, file_rotate_mode and price_format changes take effect after restart")
+	}
+
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	// 新创建的日志使用新格式
+	loggerFormatter = logger.FormatterNginx{
+		HideKeys:        newCfg.IsHideKey,
+		NoColors:        !newCfg.IsColor,
+		TimestampFormat: newCfg.TimeFormat,
+	}
+
+	// 每个日志使用自己的格式副本, 在日志的锁内替换
+	for _, ins := range []*logger.Logger{defaultLogger, apiLogger, sqlLogger} {
+		if ins != nil {
+			formatter := loggerFormatter
+			ins.SetFormatter(&formatter)
+		}
+	}
+	if priceLogger != nil && priceFormat != "binary" {
+		formatter := loggerFormatter
+		priceLogger.SetFormatter(&formatter)
+	}
+	for _, ins := range loggerMaps {
+		formatter := loggerFormatter
+		ins.SetFormatter(&formatter)
+	}
+}
+
+// newFormatter 当前日志格式的副本
+func newFormatter() *logger.FormatterNginx {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	formatter := loggerFormatter
+	return &formatter
 }
 
 func initDefaultLogger() {
 	defaultLogger = logger.New()
-	defaultLogger.SetFormatter(&loggerFormatter)
-	defaultLogger.SetOutput(io.MultiWriter(defaultLogger.NewLogFile(&logger.WriterFile{Filename: loggerFilePath + "/" + namePrefix + "default.log", RotateMode: loggerRotateMode})))
+	defaultLogger.SetFormatter(newFormatter())
+	defaultLogger.NewLogWriter(newWriterFile(loggerFilePath + "/" + namePrefix + "default.log"))
 	defaultLogger.SetLevel(logger.TraceLevel)
 }
 
 func initAPILogger() {
 	apiLogger = logger.New()
-	apiLogger.SetFormatter(&loggerFormatter)
+	apiLogger.SetFormatter(newFormatter())
 	// apiLogger.SetFormatter(&logger.FormatterText{DisableTimestamp: true})
-	apiLogger.SetOutput(io.MultiWriter(apiLogger.NewLogFile(&logger.WriterFile{Filename: loggerFilePath + "/" + namePrefix + "api.log", RotateMode: loggerRotateMode})))
+	apiLogger.NewLogWriter(newWriterFile(loggerFilePath + "/" + namePrefix + "api.log"))
 	apiLogger.SetLevel(logger.TraceLevel)
 }
 
+func initSQLLogger() {
+	sqlLogger = logger.New()
+	sqlLogger.SetFormatter(newFormatter())
+	sqlLogger.NewLogWriter(newWriterFile(loggerFilePath + "/" + namePrefix + "sql.log"))
+	sqlLogger.SetLevel(logger.TraceLevel)
+}
+
 func initPriceLogger() {
 	priceLogger = logger.New()
-	priceLogger.SetFormatter(&loggerFormatter)
+	filename := loggerFilePath + "/" + namePrefix + "price.log"
+	if priceFormat == "binary" {
+		// 二进制格式, 使用 pricedecode 转换为 csv 或 json
+		priceLogger.SetFormatter(&logger.FormatterPriceBinary{})
+		filename = loggerFilePath + "/" + namePrefix + "price.bin"
+	} else {
+		priceLogger.SetFormatter(newFormatter())
+	}
 	// spotCacheLogger.SetFormatter(&logger.FormatterText{DisableTimestamp: true})
-	priceLogger.SetOutput(io.MultiWriter(priceLogger.NewLogFile(&logger.WriterFile{Filename: loggerFilePath + "/" + namePrefix + "price.log", RotateMode: loggerRotateMode})))
+	priceLogger.NewLogWriter(newWriterFile(filename))
 	priceLogger.SetLevel(logger.TraceLevel)
 }
+
+// currentAuditLogger 已打开的审计日志, 未打开时为 nil
+func currentAuditLogger() *logger.Logger {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	return auditLogger
+}
+
+// initAuditLogger 打开审计日志, 已打开时不做处理
+func initAuditLogger() error {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+
+	if auditLogger != nil {
+		return nil
+	}
+
+	ins := logger.New()
+	ins.SetFormatter(&logger.FormatterJSON{TimestampFormat: time.RFC3339Nano})
+	ins.SetLevel(logger.TraceLevel)
+	_, err := ins.NewAuditFile(&logger.WriterAudit{
+		Filename:        loggerFilePath + "/" + namePrefix + "audit.log",
+		Key:             []byte(auditKey),
+		CheckpointLines: auditCheckpointLines,
+	})
+	if err != nil {
+		return fmt.Errorf("init audit logger: %w", err)
+	}
+	auditLogger = ins
+	return nil
+}
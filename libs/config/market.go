@@ -0,0 +1,201 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MarketURL 平台服务配置细节
+type MarketURL struct {
+	Enabled     bool   `mapstructure:"enabled" json:"enabled" default:"true"` // 是否启用, 默认启用, pub_url 为空时视为未启用
+	PubURL      string `mapstructure:"pub_url,omitempty" json:"pub_url,omitempty" validate:"url"`
+	IsPubCache  int    `mapstructure:"is_pub_cache,omitempty" json:"is_pub_cache,omitempty" validate:"oneof=0 1"`
+	PrivURL     string `mapstructure:"priv_url,omitempty" json:"priv_url,omitempty" validate:"url"`
+	IsPrivCache int    `mapstructure:"is_priv_cache,omitempty" json:"is_priv_cache,omitempty" validate:"oneof=0 1"`
+	WsURL       string `mapstructure:"ws_url" json:"ws_url,omitempty" validate:"url"`       // websocket 地址
+	ProxyURL    string `mapstructure:"proxy_url" json:"proxy_url,omitempty" validate:"url"` // 代理地址, 如 http://127.0.0.1:1080, socks5://127.0.0.1:1080
+
+	APIKey    string `mapstructure:"api_key" json:"api_key,omitempty" secret:"true"`       // API key, 支持 ${ENV}, file://, enc: 引用
+	APISecret string `mapstructure:"api_secret" json:"api_secret,omitempty" secret:"true"` // API secret, 支持 ${ENV}, file://, enc: 引用
+
+	Timeout   time.Duration `mapstructure:"timeout" json:"timeout" default:"10s"` // 单次请求超时
+	Retry     Retry         `mapstructure:"retry" json:"retry"`
+	RateLimit RateLimit     `mapstructure:"rate_limit" json:"rate_limit"`
+}
+
+// Retry 请求失败重试策略, 等待时间从 Backoff 开始每次翻倍, 不超过 MaxBackoff
+type Retry struct {
+	MaxAttempts int           `mapstructure:"max_attempts" json:"max_attempts" validate:"min=0"` // 最多重试次数, 0 不重试
+	Backoff     time.Duration `mapstructure:"backoff" json:"backoff" default:"200ms"`            // 首次重试等待时间
+	MaxBackoff  time.Duration `mapstructure:"max_backoff" json:"max_backoff" default:"5s"`       // 最长等待时间
+}
+
+// RateLimit 请求频率限制, 每个 Period 最多 Requests 个请求
+type RateLimit struct {
+	Requests int           `mapstructure:"requests" json:"requests" validate:"min=0"` // 每个周期的请求数, 0 不限制
+	Period   time.Duration `mapstructure:"period" json:"period" default:"1s"`         // 统计周期
+	Burst    int           `mapstructure:"burst" json:"burst" validate:"min=0"`       // 允许突发的请求数, 默认同 Requests
+}
+
+// MarketAPI 平台服务配置
+type MarketAPI struct {
+	Spot    MarketURL `mapstructure:"spot" json:"spot"`
+	Futures MarketURL `mapstructure:"futures" json:"futures"`
+	Swap    MarketURL `mapstructure:"swap" json:"swap"`
+	Cache   MarketURL `mapstructure:"cache" json:"cache"`
+	Extra   MarketURL `mapstructure:"extra" json:"extra"`
+}
+
+// Venues 全部平台服务配置, key 为配置中的名称, 如 spot
+func (api MarketAPI) Venues() map[string]MarketURL {
+	return map[string]MarketURL{
+		"spot":    api.Spot,
+		"futures": api.Futures,
+		"swap":    api.Swap,
+		"cache":   api.Cache,
+		"extra":   api.Extra,
+	}
+}
+
+// Venue 按名称获取启用的平台服务配置
+func (api MarketAPI) Venue(name string) (MarketURL, bool) {
+	venue, ok := api.Venues()[name]
+	if !ok || !venue.Active() {
+		return MarketURL{}, false
+	}
+	return venue, true
+}
+
+// String 输出时隐藏 API key 和 secret
+func (api MarketAPI) String() string {
+	venues := api.Venues()
+	names := make([]string, 0, len(venues))
+	for name := range venues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := "{"
+	for i, name := range names {
+		if i > 0 {
+			s += " "
+		}
+		s += name + ":" + venues[name].String()
+	}
+	return s + "}"
+}
+
+// String 输出时隐藏 API key 和 secret
+func (u MarketURL) String() string {
+	return fmt.Sprintf("{enabled:%t pub:%s priv:%s ws:%s proxy:%s key:%s timeout:%s retry:%d rate:%d/%s}",
+		u.Enabled, u.PubURL, u.PrivURL, u.WsURL, maskURL(u.ProxyURL), maskValue(u.APIKey),
+		u.Timeout, u.Retry.MaxAttempts, u.RateLimit.Requests, u.RateLimit.Period)
+}
+
+// GoString 同 String, 避免 %#v 输出密钥
+func (u MarketURL) GoString() string {
+	return "config.MarketURL" + u.String()
+}
+
+// Active 是否可用, 启用且配置了 pub_url, pub_url 为空时视为未启用
+func (u MarketURL) Active() bool {
+	return u.Enabled && u.PubURL != ""
+}
+
+// PubCache 公共接口是否使用缓存
+func (u MarketURL) PubCache() bool {
+	return u.IsPubCache == 1
+}
+
+// PrivCache 私有接口是否使用缓存
+func (u MarketURL) PrivCache() bool {
+	return u.IsPrivCache == 1
+}
+
+// Credentials API key 和 secret, 已解析引用
+func (u MarketURL) Credentials() (key, secret string) {
+	return u.APIKey, u.APISecret
+}
+
+// HasCredentials 是否配置了 API key 和 secret
+func (u MarketURL) HasCredentials() bool {
+	return u.APIKey != "" && u.APISecret != ""
+}
+
+// RequestTimeout 单次请求超时, 未配置时为 10s
+func (u MarketURL) RequestTimeout() time.Duration {
+	if u.Timeout <= 0 {
+		return time.Second * 10
+	}
+	return u.Timeout
+}
+
+// Proxy 代理地址, 未配置时返回 nil, 可用于 http.Transport.Proxy = http.ProxyURL(proxy)
+func (u MarketURL) Proxy() (*url.URL, error) {
+	if u.ProxyURL == "" {
+		return nil, nil
+	}
+	return url.Parse(u.ProxyURL)
+}
+
+// Delay 第 attempt 次重试 (从 1 开始) 前的等待时间, 超过 MaxAttempts 时返回 false
+func (r Retry) Delay(attempt int) (time.Duration, bool) {
+	if attempt < 1 || attempt > r.MaxAttempts {
+		return 0, false
+	}
+
+	delay := r.Backoff
+	for i := 1; i < attempt && (r.MaxBackoff <= 0 || delay < r.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return delay, true
+}
+
+// Limited 是否限制请求频率
+func (r RateLimit) Limited() bool {
+	return r.Requests > 0 && r.Period > 0
+}
+
+// Interval 平均每个请求的间隔, 不限制时为 0
+func (r RateLimit) Interval() time.Duration {
+	if !r.Limited() {
+		return 0
+	}
+	return r.Period / time.Duration(r.Requests)
+}
+
+// BurstSize 允许突发的请求数, 未配置时同 Requests
+func (r RateLimit) BurstSize() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Requests
+}
+
+func maskValue(s string) string {
+	if s == "" {
+		return ""
+	}
+	return SecretMask
+}
+
+// maskURL 隐藏地址中的密码
+func maskURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+	// 直接设置 SecretMask 会被转义为 %2A, 在用户名后插入
+	u.User = url.User(u.User.Username())
+	user := u.User.String() + "@"
+	return strings.Replace(u.String(), user, strings.TrimSuffix(user, "@")+":"+SecretMask+"@", 1)
+}
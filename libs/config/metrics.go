@@ -2,7 +2,7 @@ package config
 
 import (
 	"fmt"
-	"strings"
+	"sync/atomic"
 	"time"
 
 	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
@@ -12,13 +12,26 @@ import (
 
 // Metrics
 type Metrics struct {
-	APICounter   *kitprometheus.Counter // API 计数器
-	APISummary   *kitprometheus.Summary // API 延时统计
-	SvcGauge     *kitprometheus.Gauge   // 服务状态
-	ErrorDetails bool
+	APICounter   *kitprometheus.Counter   // API 计数器
+	APISummary   *kitprometheus.Summary   // API 延时统计, 已废弃, 名称为 _ms 实际单位为秒, 迁移到 APIHistogram 后关闭
+	APIHistogram *kitprometheus.Histogram // API 延时分布(秒), 可以跨实例聚合
+	SvcGauge     *kitprometheus.Gauge     // 服务状态
+	FlagGauge    *kitprometheus.Gauge     // 功能开关生效比例
+	PipelineSize *kitprometheus.Histogram // Redis pipeline 命令数
+	Unclassified *kitprometheus.Counter   // 未分类错误计数, 用于补充错误分类规则
+	BuildInfo    *kitprometheus.Gauge     // 编译信息, 值固定为 1
+	ErrorDetails bool                     // error 标签使用错误分类, 运行中修改使用 SetErrorDetails
+	Errors       *ErrorClassifier         // error 标签的分类表, 使用 DefaultErrors 的规则, 单独限制标签数量
+
+	errorDetails int32 // SetErrorDetails 设置的值, 0 未设置, 使用 ErrorDetails
 }
 
 func NewMetrics(ns string, sys string, details bool) *Metrics {
+	return NewMetricsFromConfig(ns, sys, Monitor{ErrorDetails: details, LatencySummary: true})
+}
+
+// NewMetricsFromConfig 按监控配置创建指标, 包括直方图分桶, 是否保留旧的 summary 和错误标签数量上限
+func NewMetricsFromConfig(ns string, sys string, cfg Monitor) *Metrics {
 	fieldKeys := []string{"svc", "api", "error"}
 	apiCount := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
 		Namespace: ns,
@@ -26,11 +39,25 @@ func NewMetrics(ns string, sys string, details bool) *Metrics {
 		Name:      "api_count",
 		Help:      "Number of requests received.",
 	}, fieldKeys)
-	apiSummary := kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+	var apiSummary *kitprometheus.Summary
+	if cfg.LatencySummary {
+		apiSummary = kitprometheus.NewSummaryFrom(stdprometheus.SummaryOpts{
+			Namespace: ns,
+			Subsystem: sys,
+			Name:      "api_latency_ms",
+			Help:      "Duration of requests in seconds, despite the _ms suffix. Deprecated, use api_latency_seconds.",
+		}, fieldKeys)
+	}
+	buckets := cfg.LatencyBuckets
+	if len(buckets) == 0 {
+		buckets = stdprometheus.DefBuckets
+	}
+	apiHistogram := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
 		Namespace: ns,
 		Subsystem: sys,
-		Name:      "api_latency_ms",
-		Help:      "Total duration of requests in microseconds.",
+		Name:      "api_latency_seconds",
+		Help:      "Duration of requests in seconds.",
+		Buckets:   buckets,
 	}, fieldKeys)
 
 	fieldKeys = []string{"svc", "name", "type"}
@@ -41,45 +68,104 @@ func NewMetrics(ns string, sys string, details bool) *Metrics {
 		Help:      "Service status in different view point.",
 	}, fieldKeys)
 
+	fieldKeys = []string{"svc", "flag", "symbol"}
+	flagGauge := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: sys,
+		Name:      "feature_flag_percent",
+		Help:      "Rollout percentage of feature flags, 0 is off and 100 is fully on.",
+	}, fieldKeys)
+
+	pipelineSize := kitprometheus.NewHistogramFrom(stdprometheus.HistogramOpts{
+		Namespace: ns,
+		Subsystem: sys,
+		Name:      "redis_pipeline_size",
+		Help:      "Number of commands in a Redis pipeline.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+	}, []string{"svc"})
+
+	unclassified := kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sys,
+		Name:      "errors_unclassified_total",
+		Help:      "Number of errors labeled as other because no classification rule matched or the label limit was reached.",
+	}, []string{"svc", "api"})
+
+	buildInfo := kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: sys,
+		Name:      "build_info",
+		Help:      "Build information of the running binary, always 1.",
+	}, []string{"version", "commit", "build_time", "go_version"})
+	build := Build()
+	buildInfo.With("version", build.Version, "commit", build.Commit, "build_time", build.BuildTime, "go_version", build.GoVersion).Set(1)
+
 	metrics := &Metrics{
 		APICounter:   apiCount,
 		APISummary:   apiSummary,
+		APIHistogram: apiHistogram,
 		SvcGauge:     svcGauge,
-		ErrorDetails: details,
+		FlagGauge:    flagGauge,
+		PipelineSize: pipelineSize,
+		Unclassified: unclassified,
+		BuildInfo:    buildInfo,
+		ErrorDetails: cfg.ErrorDetails,
+		Errors:       DefaultErrors.Limit(cfg.MaxErrorLabels),
 	}
 
 	return metrics
 }
 
-func getLastError(err error) string {
-	msg := err.Error()
-
-	msgs := strings.Split(msg, " ")
-	length := len(msgs)
+// SetErrorDetails 运行中开关错误分类, 可以与请求并发调用, 用于配置热更新
+func (metrics *Metrics) SetErrorDetails(on bool) {
+	value := int32(1)
+	if on {
+		value = 2
+	}
+	atomic.StoreInt32(&metrics.errorDetails, value)
+}
 
-	lst := msgs[length-1]
+// ErrorDetailsEnabled error 标签是否使用错误分类
+func (metrics *Metrics) ErrorDetailsEnabled() bool {
+	switch atomic.LoadInt32(&metrics.errorDetails) {
+	case 1:
+		return false
+	case 2:
+		return true
+	}
+	return metrics.ErrorDetails
+}
 
-	return lst
+// errorLabel error 标签, 开启 ErrorDetails 时为错误分类, 否则为 true/false
+func (metrics *Metrics) errorLabel(err error) (label string, classified bool) {
+	if !metrics.ErrorDetailsEnabled() || err == nil {
+		return fmt.Sprint(err != nil), true
+	}
+	errs := metrics.Errors
+	if errs == nil {
+		errs = DefaultErrors
+	}
+	return errs.Classify(err)
 }
 
+// AddApiCounter 请求计数, 未分类的错误同时计入 errors_unclassified_total
 func (metrics *Metrics) AddApiCounter(svc string, api string, err error) {
-	var lvs []string
-	if metrics.ErrorDetails && err != nil {
-		lvs = []string{"svc", svc, "api", api, "error", getLastError(err)}
-	} else {
-		lvs = []string{"svc", svc, "api", api, "error", fmt.Sprint(err != nil)}
+	label, classified := metrics.errorLabel(err)
+	if !classified && metrics.Unclassified != nil {
+		metrics.Unclassified.With("svc", svc, "api", api).Add(1)
 	}
-	metrics.APICounter.With(lvs...).Add(1)
+	metrics.APICounter.With("svc", svc, "api", api, "error", label).Add(1)
 }
 
+// SetApiSummary 记录从 begin 开始的请求耗时(秒), 同时写入 api_latency_seconds 直方图和未关闭的旧 summary
 func (metrics *Metrics) SetApiSummary(svc string, api string, err error, begin time.Time) {
-	var lvs []string
-	if metrics.ErrorDetails && err != nil {
-		lvs = []string{"svc", svc, "api", api, "error", getLastError(err)}
-	} else {
-		lvs = []string{"svc", svc, "api", api, "error", fmt.Sprint(err != nil)}
+	label, _ := metrics.errorLabel(err)
+	lvs := []string{"svc", svc, "api", api, "error", label}
+	seconds := time.Since(begin).Seconds()
+	metrics.APIHistogram.With(lvs...).Observe(seconds)
+	if metrics.APISummary != nil {
+		metrics.APISummary.With(lvs...).Observe(seconds)
 	}
-	metrics.APISummary.With(lvs...).Observe(time.Since(begin).Seconds())
 }
 
 func (metrics *Metrics) SetSvcValue(svc string, name string, tp string, value float64) {
@@ -87,6 +173,17 @@ func (metrics *Metrics) SetSvcValue(svc string, name string, tp string, value fl
 	metrics.SvcGauge.With(lvs...).Set(value)
 }
 
+// SetFlagValue 功能开关生效比例, 全部服务或全部交易对的标签值为 *
+func (metrics *Metrics) SetFlagValue(svc string, flag string, symbol string, percent int) {
+	lvs := []string{"svc", svc, "flag", flag, "symbol", symbol}
+	metrics.FlagGauge.With(lvs...).Set(float64(percent))
+}
+
+// ObservePipelineSize 记录 Redis pipeline 命令数
+func (metrics *Metrics) ObservePipelineSize(svc string, size int) {
+	metrics.PipelineSize.With("svc", svc).Observe(float64(size))
+}
+
 func (metrics *Metrics) SetSvcInt16(svc string, name string, tp string, value int16) {
 	v := float64(value)
 	metrics.SetSvcValue(svc, name, tp, v)
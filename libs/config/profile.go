@@ -0,0 +1,196 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+var (
+	// configFiles 生效的配置文件, 按合并顺序
+	configFiles []string
+	// keySources 配置项 -> 提供该配置项的文件
+	keySources map[string]string
+	// watchedAt 文件监听最近一次确认存活的时间
+	watchedAt time.Time
+)
+
+// watchHeartbeat 文件监听的存活周期, 超过 3 个周期未更新视为监听已停止
+const watchHeartbeat = time.Second * 10
+
+// WatchedAt 配置文件监听最近一次确认存活的时间, 监听启动失败或已退出时不再更新
+func WatchedAt() time.Time {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return watchedAt
+}
+
+// WatchWindow 配置文件监听的有效期, 超过该时长 WatchedAt 未更新时修改配置文件不会生效
+func WatchWindow() time.Duration {
+	return watchHeartbeat * 3
+}
+
+func touchWatched() {
+	confMu.Lock()
+	watchedAt = time.Now()
+	confMu.Unlock()
+}
+
+// layerFiles 配置文件合并顺序: config.yaml, config.<env>.yaml, config.local.yaml
+func layerFiles(base string) []string {
+	dir, ext := filepath.Dir(base), filepath.Ext(base)
+	name := strings.TrimSuffix(filepath.Base(base), ext)
+
+	files := []string{base}
+	if env != "" {
+		files = append(files, filepath.Join(dir, name+"."+env+ext))
+	}
+	return append(files, filepath.Join(dir, name+".local"+ext))
+}
+
+// readConfigFiles 读取 config.yaml, 再依次深度合并存在的 config.<env>.yaml 和 config.local.yaml,
+// 然后合并远程配置, 最后应用环境变量覆盖
+func readConfigFiles() error {
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+
+	base := viper.ConfigFileUsed()
+	files := []string{base}
+	sources := make(map[string]string)
+	for _, key := range viper.AllKeys() {
+		sources[key] = base
+	}
+
+	for _, file := range layerFiles(base)[1:] {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			continue
+		}
+
+		layer := viper.New()
+		layer.SetConfigFile(file)
+		if err := layer.ReadInConfig(); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		if err := viper.MergeConfigMap(layer.AllSettings()); err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+
+		for _, key := range layer.AllKeys() {
+			sources[key] = file
+		}
+		files = append(files, file)
+	}
+
+	if err := mergeRemote(sources); err != nil {
+		return err
+	}
+	if err := applyEnvOverrides(sources); err != nil {
+		return err
+	}
+
+	confMu.Lock()
+	configFiles, keySources = files, sources
+	confMu.Unlock()
+
+	return nil
+}
+
+// ConfigFiles 生效的配置文件, 按合并顺序
+func ConfigFiles() []string {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return append([]string(nil), configFiles...)
+}
+
+// KeySources 配置项 -> 提供该配置项的文件
+func KeySources() map[string]string {
+	confMu.RLock()
+	defer confMu.RUnlock()
+
+	sources := make(map[string]string, len(keySources))
+	for key, source := range keySources {
+		sources[key] = source
+	}
+	return sources
+}
+
+// logKeySources 输出每个配置文件提供的配置项
+func logKeySources() {
+	files := make(map[string][]string)
+	for key, source := range KeySources() {
+		if strings.HasPrefix(source, "env:") {
+			source = "env"
+		}
+		if strings.HasPrefix(source, "remote:") {
+			source = strings.TrimPrefix(source, "remote:")
+		}
+		files[source] = append(files[source], key)
+	}
+
+	confMu.RLock()
+	remote := remoteName
+	confMu.RUnlock()
+
+	for _, file := range append(ConfigFiles(), remote, "env") {
+		keys := files[file]
+		if len(keys) == 0 {
+			continue
+		}
+		sort.Strings(keys)
+		log.Printf("config %s supplies %s", file, strings.Join(keys, ", "))
+	}
+}
+
+// watchConfig 监听所有层级的配置文件, 包括尚未创建的 config.<env>.yaml 和 config.local.yaml
+func watchConfig() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	files := layerFiles(viper.ConfigFileUsed())
+	watched := make(map[string]bool)
+	for _, file := range files {
+		watched[filepath.Clean(file)] = true
+	}
+	if err := watcher.Add(filepath.Dir(files[0])); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	touchWatched()
+	go func() {
+		ticker := time.NewTicker(watchHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				touchWatched()
+			case event, ok := <-watcher.Events:
+				if !ok {
+					log.Printf("config watcher stopped")
+					return
+				}
+				if watched[filepath.Clean(event.Name)] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) != 0 {
+					onConfigChange(event)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					log.Printf("config watcher stopped")
+					return
+				}
+				log.Printf("config watcher error: %s", err)
+			}
+		}
+	}()
+
+	return nil
+}
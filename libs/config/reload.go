@@ -0,0 +1,74 @@
+package config
+
+import (
+	"log"
+	"sync"
+)
+
+var (
+	// reloadMu 串行化 Reload, 文件监听和远程配置拉取在不同的 goroutine 中触发,
+	// 读取文件, 合并, 解析到通知订阅者必须整体完成, 避免较慢的 Reload 用旧配置覆盖新配置
+	reloadMu sync.Mutex
+
+	confMu      sync.RWMutex
+	current     *Config
+	subscribers []func(old, new *Config)
+)
+
+// Current 当前生效的配置
+func Current() *Config {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return current
+}
+
+// OnChange 注册配置变更回调, 配置文件修改并校验通过后按注册顺序调用.
+// old 和 new 不应被修改.
+func OnChange(f func(old, new *Config)) {
+	confMu.Lock()
+	defer confMu.Unlock()
+	subscribers = append(subscribers, f)
+}
+
+func setCurrent(conf *Config) (old *Config, subs []func(old, new *Config)) {
+	confMu.Lock()
+	defer confMu.Unlock()
+	old, current = current, conf
+	return old, append(subs, subscribers...)
+}
+
+// Reload 重新解析配置并通知订阅者, 解析或校验失败时保留当前配置
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	return reload()
+}
+
+// reload 调用方需持有 reloadMu
+func reload() error {
+	if err := readConfigFiles(); err != nil {
+		return err
+	}
+
+	conf, err := parseConf()
+	if err != nil {
+		return err
+	}
+
+	// 编辑器保存时会触发多次事件, 配置没有变化时不通知
+	diffs := DiffConfig(Current(), conf)
+	if len(diffs) == 0 {
+		return nil
+	}
+	for _, diff := range diffs {
+		log.Printf("config changed %s", diff)
+	}
+	auditConfigChange(diffs)
+
+	old, subs := setCurrent(conf)
+	for _, f := range subs {
+		f(old, conf)
+	}
+	return nil
+}
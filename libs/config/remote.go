@@ -0,0 +1,276 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Remote 远程配置源, 从 MySQL 表或 Redis hash 中读取配置项覆盖配置文件
+type Remote struct {
+	Provider string        `mapstructure:"provider" json:"provider" validate:"oneof=mysql redis"` // mysql 或 redis, 为空不启用
+	Table    string        `mapstructure:"table" json:"table" default:"service_config"`           // MySQL 表名, 默认 service_config
+	Key      string        `mapstructure:"key" json:"key"`                                        // Redis hash 键名, 默认 <redis.prefix>config:<name>
+	Channel  string        `mapstructure:"channel" json:"channel"`                                // Redis 变更通知频道, 为空只定期拉取
+	Interval time.Duration `mapstructure:"interval" json:"interval" default:"30s"`                // 拉取周期, 默认 30s
+	MaxAge   time.Duration `mapstructure:"max_age" json:"max_age"`                                // 超过该时长未成功拉取时 /readyz 失败, 默认 3 个拉取周期
+}
+
+// FreshnessWindow 远程配置的有效期, 未配置 max_age 时为 3 个拉取周期
+func (remote Remote) FreshnessWindow() time.Duration {
+	if remote.MaxAge > 0 {
+		return remote.MaxAge
+	}
+	interval := remote.Interval
+	if interval <= 0 {
+		interval = time.Second * 30
+	}
+	return interval * 3
+}
+
+// RemoteSource 远程配置源, Load 返回 配置项路径 -> 值, 如 monitor.error_details -> true
+type RemoteSource interface {
+	Name() string
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+// RemoteWatcher 支持订阅变更的远程配置源, 收到变更时调用 notify
+type RemoteWatcher interface {
+	Watch(ctx context.Context, notify func()) error
+}
+
+// MySQLSource 从 MySQL 表读取配置: service_id, config_key, config_value
+type MySQLSource struct {
+	DB        *gorm.DB
+	Table     string
+	ServiceID string
+}
+
+func (src *MySQLSource) Name() string {
+	return "mysql:" + src.Table
+}
+
+func (src *MySQLSource) Load(ctx context.Context) (map[string]string, error) {
+	var rows []struct {
+		ConfigKey   string
+		ConfigValue string
+	}
+	err := src.DB.WithContext(ctx).
+		Table(src.Table).
+		Select("config_key", "config_value").
+		Where("service_id = ?", src.ServiceID).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(rows))
+	for _, row := range rows {
+		values[row.ConfigKey] = row.ConfigValue
+	}
+	return values, nil
+}
+
+// RedisSource 从 Redis hash 读取配置, field 为配置项路径
+type RedisSource struct {
+	Client  redis.UniversalClient
+	Key     string
+	Channel string
+}
+
+func (src *RedisSource) Name() string {
+	return "redis:" + src.Key
+}
+
+func (src *RedisSource) Load(ctx context.Context) (map[string]string, error) {
+	return src.Client.HGetAll(ctx, src.Key).Result()
+}
+
+// Watch 订阅 Channel, 任意消息都触发重新加载, ctx 结束时取消订阅并关闭连接
+func (src *RedisSource) Watch(ctx context.Context, notify func()) error {
+	if src.Channel == "" {
+		return nil
+	}
+
+	sub := src.Client.Subscribe(ctx, src.Channel)
+	go func() {
+		defer sub.Close()
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				notify()
+			}
+		}
+	}()
+	return nil
+}
+
+// NewRemoteSource 根据配置创建远程配置源, 未启用时返回 nil
+func NewRemoteSource(cfg Remote, name string, db *gorm.DB, client redis.UniversalClient, prefix string) (RemoteSource, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "mysql":
+		table := cfg.Table
+		if table == "" {
+			table = "service_config"
+		}
+		return &MySQLSource{DB: db, Table: table, ServiceID: name}, nil
+	case "redis":
+		key := cfg.Key
+		if key == "" {
+			key = prefix + "config:" + name
+		}
+		return &RedisSource{Client: client, Key: key, Channel: cfg.Channel}, nil
+	}
+	return nil, fmt.Errorf("unknown remote config provider %q", cfg.Provider)
+}
+
+var (
+	// remoteName 远程配置源名称
+	remoteName string
+	// remoteValues 远程配置项
+	remoteValues map[string]string
+	// remoteSyncedAt 最近一次成功拉取的时间, 不论拉取的配置是否通过校验
+	remoteSyncedAt time.Time
+	// remoteApplyErr 最近一次拉取到的配置生效失败的原因
+	remoteApplyErr error
+)
+
+// RemoteSyncedAt 最近一次成功拉取远程配置的时间, 未启用远程配置时为零值
+func RemoteSyncedAt() time.Time {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return remoteSyncedAt
+}
+
+// RemoteApplyError 最近一次拉取到的远程配置未能生效的原因, 此时仍使用之前的配置
+func RemoteApplyError() error {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return remoteApplyErr
+}
+
+// StartRemote 加载远程配置并重新生效, 之后按 interval 拉取, 支持订阅的配置源收到通知时立即拉取.
+// 变更与配置文件修改一样通过 OnChange 通知.
+func StartRemote(ctx context.Context, src RemoteSource, interval time.Duration) error {
+	if err := pollRemote(ctx, src); err != nil {
+		return err
+	}
+
+	if interval <= 0 {
+		interval = time.Second * 30
+	}
+
+	notify := make(chan struct{}, 1)
+	if watcher, ok := src.(RemoteWatcher); ok {
+		err := watcher.Watch(ctx, func() {
+			select {
+			case notify <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-notify:
+			}
+			if err := pollRemote(ctx, src); err != nil {
+				log.Printf("remote config %s: %s", src.Name(), err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// pollRemote 拉取远程配置, 有变化时重新生效. 生效失败时恢复之前的远程配置项,
+// 避免未通过校验的值留在合并结果中, 下次拉取时重试.
+func pollRemote(ctx context.Context, src RemoteSource) error {
+	values, err := src.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	confMu.Lock()
+	remoteSyncedAt = time.Now()
+	prevName, prevValues := remoteName, remoteValues
+	changed := src.Name() != remoteName || !reflect.DeepEqual(values, remoteValues)
+	remoteName, remoteValues = src.Name(), values
+	confMu.Unlock()
+
+	if !changed {
+		return nil
+	}
+
+	err = reload()
+
+	confMu.Lock()
+	defer confMu.Unlock()
+	if err != nil {
+		remoteName, remoteValues = prevName, prevValues
+	}
+	remoteApplyErr = err
+	return err
+}
+
+// mergeRemote 合并远程配置项, 优先级高于配置文件, 低于环境变量
+func mergeRemote(sources map[string]string) error {
+	confMu.RLock()
+	name, values := remoteName, remoteValues
+	confMu.RUnlock()
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	types := make(map[string]reflect.Type)
+	walkConfig(reflect.ValueOf(Config{}), "", func(key string, field reflect.StructField, _ reflect.Value) {
+		types[key] = field.Type
+	})
+
+	settings := make(map[string]interface{})
+	for key, value := range values {
+		key = strings.ToLower(key)
+		t, ok := types[key]
+		if !ok {
+			log.Printf("remote config %s: unknown key %s ignored", name, key)
+			continue
+		}
+		// 与环境变量相同, 列表和映射使用 JSON
+		v, err := envValue(t, value)
+		if err != nil {
+			return fmt.Errorf("remote config %s: %s: %s", name, key, err)
+		}
+		setNested(settings, strings.Split(key, "."), v)
+		sources[key] = "remote:" + name
+	}
+
+	return viper.MergeConfigMap(settings)
+}
@@ -0,0 +1,460 @@
+package config
+
+import (
+	_ "embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run ../../cmd/configschema -o config.schema.json
+
+// SchemaID JSON Schema $id, 编辑器通过 `# yaml-language-server: $schema=...` 引用
+const SchemaID = "config.schema.json"
+
+// durationPattern time.ParseDuration 接受的格式, 如 1m30s
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//go:embed config.schema.json
+var schemaJSON []byte
+
+// Schema 生成好的配置 JSON Schema, 由 go generate 更新
+func Schema() []byte {
+	return schemaJSON
+}
+
+// FieldComments 解析 dir 下配置结构体的注释, key 为 `类型名` 或 `类型名.字段名`.
+// 类型注释去掉开头的类型名, 字段优先使用行尾注释.
+func FieldComments(dir string) (map[string]string, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make(map[string]string)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						continue
+					}
+
+					typeName := typeSpec.Name.Name
+					doc := typeSpec.Doc
+					if doc == nil {
+						doc = gen.Doc
+					}
+					if text := commentText(doc); text != "" {
+						fields := strings.Fields(text)
+						if strings.EqualFold(fields[0], typeName) {
+							text = strings.TrimSpace(strings.TrimPrefix(text, fields[0]))
+						}
+						comments[typeName] = text
+					}
+
+					for _, field := range structType.Fields.List {
+						text := commentText(field.Comment)
+						if text == "" {
+							text = commentText(field.Doc)
+						}
+						if text == "" {
+							continue
+						}
+						for _, name := range field.Names {
+							comments[typeName+"."+name.Name] = text
+						}
+					}
+				}
+			}
+		}
+	}
+	return comments, nil
+}
+
+func commentText(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(group.Text()), " ")
+}
+
+// GenerateSchema 反射 Config 生成 JSON Schema (draft-07).
+// 类型和字段名取 mapstructure 标签, enum 取 validate 的 oneof, minimum/maximum 取 min/max,
+// default 取 default 标签, description 取 comments.
+func GenerateSchema(comments map[string]string) map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(Config{}), comments)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["$id"] = SchemaID
+	schema["title"] = "config.yaml"
+	return schema
+}
+
+func typeSchema(t reflect.Type, comments map[string]string) map[string]interface{} {
+	if t == durationType {
+		return map[string]interface{}{
+			"type":    []string{"string", "integer"},
+			"pattern": durationPattern,
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" || field.Tag.Get("mapstructure") == "-" {
+				continue
+			}
+			properties[tagName(field)] = fieldSchema(t, field, comments)
+		}
+
+		schema := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+		if desc := comments[t.Name()]; desc != "" {
+			schema["description"] = desc
+		}
+		return schema
+	case reflect.Slice:
+		schema := map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem(), comments),
+		}
+		// 字符串列表同时支持逗号分隔
+		if t.Elem().Kind() == reflect.String {
+			schema["type"] = []string{"array", "string"}
+		}
+		return schema
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem(), comments),
+		}
+	case reflect.Ptr:
+		// 指针表示可以不配置, 与元素类型相同
+		return typeSchema(t.Elem(), comments)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+func fieldSchema(parent reflect.Type, field reflect.StructField, comments map[string]string) map[string]interface{} {
+	schema := typeSchema(field.Type, comments)
+
+	if desc := comments[parent.Name()+"."+field.Name]; desc != "" {
+		schema["description"] = desc
+	}
+	if field.Tag.Get("secret") == "true" {
+		schema["writeOnly"] = true
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "oneof":
+			var enum []interface{}
+			for _, option := range strings.Fields(arg) {
+				enum = append(enum, schemaValue(field.Type, option))
+			}
+			// 未配置时为零值
+			if zero := schemaValue(field.Type, ""); zero != nil && !containsValue(enum, zero) {
+				enum = append(enum, zero)
+			}
+			schema["enum"] = enum
+		case "min":
+			schema["minimum"] = schemaValue(field.Type, arg)
+		case "max":
+			schema["maximum"] = schemaValue(field.Type, arg)
+		case "url":
+			schema["format"] = "uri"
+		}
+	}
+
+	if value, ok := field.Tag.Lookup("default"); ok {
+		schema["default"] = schemaValue(field.Type, value)
+	}
+
+	return schema
+}
+
+// schemaValue 标签中的字符串转换为字段类型对应的 JSON 值
+func schemaValue(t reflect.Type, value string) interface{} {
+	if t == durationType {
+		return value
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaValue(t.Elem(), value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			return 0
+		}
+		n, _ := strconv.ParseInt(value, 10, 64)
+		return n
+	case reflect.Bool:
+		b, _ := strconv.ParseBool(value)
+		return b
+	}
+	return value
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckConfigFile 按 Schema 校验 yaml 配置文件, 返回全部不符合的配置项.
+// 只校验文件本身, 分层合并的文件可以只包含部分配置项.
+func CheckConfigFile(filename string) (ValidationErrors, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, nil
+	}
+
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal(Schema(), &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema: %s", err)
+	}
+
+	return checkSchema(schema, doc, ""), nil
+}
+
+// checkSchema 校验 GenerateSchema 生成的 schema 所用到的关键字
+func checkSchema(schema map[string]interface{}, value interface{}, path string) (errs ValidationErrors) {
+	fail := func(rule, format string, args ...interface{}) ValidationErrors {
+		key := path
+		if key == "" {
+			key = "(root)"
+		}
+		return append(errs, FieldError{Path: key, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if isSchemaType(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("type", "must be %s, got %s", strings.Join(types, " or "), yamlTypeName(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		options := make([]string, 0, len(enum))
+		for _, option := range enum {
+			options = append(options, fmt.Sprintf("%q", fmt.Sprint(option)))
+		}
+		return fail("enum", "%q is not one of %s", fmt.Sprint(value), strings.Join(options, ", "))
+	}
+
+	switch v := value.(type) {
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+			errs = fail("pattern", "%q does not match %s", v, pattern)
+		}
+	case int, float64:
+		n, _ := toFloat(v)
+		if min, ok := toFloat(schema["minimum"]); ok && n < min {
+			errs = fail("minimum", "must be at least %v", schema["minimum"])
+		}
+		if max, ok := toFloat(schema["maximum"]); ok && n > max {
+			errs = fail("maximum", "must be at most %v", schema["maximum"])
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				errs = append(errs, checkSchema(items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if property, ok := properties[key].(map[string]interface{}); ok {
+				errs = append(errs, checkSchema(property, v[key], child)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, FieldError{Path: child, Rule: "additionalProperties", Message: "unknown config key" + suggestKey(key, properties)})
+				}
+			case map[string]interface{}:
+				errs = append(errs, checkSchema(additional, v[key], child)...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func schemaTypes(t interface{}) []string {
+	switch t := t.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+	return nil
+}
+
+func isSchemaType(t string, value interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok || value == nil
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	}
+	return false
+}
+
+func yamlTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, float64:
+		return "number"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// suggestKey 拼写接近的配置项提示
+func suggestKey(key string, properties map[string]interface{}) string {
+	best, bestDist := "", 3
+	for name := range properties {
+		if dist := editDistance(key, name); dist < bestDist || (dist == bestDist && name < best) {
+			best, bestDist = name, dist
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// SecretKeyEnv 解密 enc: 配置值的密钥环境变量, base64 编码的 16/24/32 字节 AES 密钥
+const SecretKeyEnv = "CONFIG_SECRET_KEY"
+
+// SecretMask 密文配置在日志和输出中的替代值
+const SecretMask = "******"
+
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// IsSecretKey 当前配置中该配置项是否为密文, 密文不应出现在日志和配置输出中.
+// map 和 slice 中的元素路径如 mysql.params.password, redis.addrs[0].
+func IsSecretKey(key string) bool {
+	conf := Current()
+	return conf != nil && conf.secrets[key]
+}
+
+// resolveSecrets 解析字符串配置中的引用, 包括 map 和 slice 中的字符串:
+//
+//	${ENV_VAR}                   替换为环境变量
+//	file:///run/secrets/name     读取文件内容, 去掉末尾换行
+//	enc:<base64>                 使用 CONFIG_SECRET_KEY 解密 AES-GCM 密文
+//
+// 带 secret 标签或使用了引用的配置项记录在 conf 中, 配置生效后才用于隐藏输出.
+func resolveSecrets(conf *Config) error {
+	secrets := make(map[string]bool)
+	errs := resolveValue(reflect.ValueOf(conf).Elem(), "", secrets)
+	conf.secrets = secrets
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// resolveValue 解析 value 中的字符串引用, 递归处理 struct, slice 和 map
+func resolveValue(value reflect.Value, key string, secrets map[string]bool) (errs ValidationErrors) {
+	switch value.Kind() {
+	case reflect.String:
+		raw := value.String()
+		resolved, err := resolveSecret(raw)
+		if err != nil {
+			return ValidationErrors{FieldError{Path: key, Rule: "secret", Message: err.Error()}}
+		}
+		if resolved != raw {
+			secrets[key] = true
+			value.SetString(resolved)
+		}
+	case reflect.Struct:
+		walkConfig(value, key, func(key string, field reflect.StructField, value reflect.Value) {
+			if field.Tag.Get("secret") == "true" {
+				secrets[key] = true
+			}
+			errs = append(errs, resolveValue(value, key, secrets)...)
+		})
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			errs = append(errs, resolveValue(value.Index(i), fmt.Sprintf("%s[%d]", key, i), secrets)...)
+		}
+	case reflect.Map:
+		// map 的元素不可寻址, 解析副本后写回
+		iter := value.MapRange()
+		for iter.Next() {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			errs = append(errs, resolveValue(elem, key+"."+fmt.Sprint(iter.Key().Interface()), secrets)...)
+			value.SetMapIndex(iter.Key(), elem)
+		}
+	}
+	return errs
+}
+
+func resolveSecret(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, "file://"):
+		data, err := os.ReadFile(strings.TrimPrefix(raw, "file://"))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case strings.HasPrefix(raw, "enc:"):
+		return DecryptSecret(strings.TrimPrefix(raw, "enc:"))
+	}
+
+	var missing []string
+	resolved := envRefPattern.ReplaceAllStringFunc(raw, func(ref string) string {
+		name := envRefPattern.FindStringSubmatch(ref)[1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s not set", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+func secretCipher() (cipher.AEAD, error) {
+	encoded := os.Getenv(SecretKeyEnv)
+	if encoded == "" {
+		return nil, fmt.Errorf("%s not set", SecretKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", SecretKeyEnv, err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", SecretKeyEnv, err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptSecret 使用 CONFIG_SECRET_KEY 加密, 返回可直接写入配置文件的 enc: 值
+func EncryptSecret(plain string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return "enc:" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret 解密 EncryptSecret 生成的密文, 不含 enc: 前缀
+func DecryptSecret(encoded string) (string, error) {
+	gcm, err := secretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("decrypt failed, wrong key or corrupted value")
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldError 单个配置项校验错误
+type FieldError struct {
+	Path    string // yaml 路径, 如 mysql.port
+	Rule    string // 未通过的规则, 如 max=65535
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors 配置校验错误汇总
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d invalid config values:\n  %s", len(errs), strings.Join(msgs, "\n  "))
+}
+
+// Validate 按 validate 标签校验配置, 一次返回全部错误.
+//
+// 支持的规则:
+//
+//	required     不能为零值
+//	url          非空时为 scheme://host 格式的地址
+//	hostport     非空时为 host:port 格式的地址
+//	min=N max=N  数值范围, 非零值时校验
+//	oneof=a b c  非空时取值范围
+func (conf *Config) Validate() error {
+	var errs ValidationErrors
+	walkConfig(reflect.ValueOf(conf).Elem(), "", func(key string, field reflect.StructField, value reflect.Value) {
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			return
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			if msg := checkRule(rule, value); msg != "" {
+				errs = append(errs, FieldError{Path: key, Rule: rule, Message: msg})
+			}
+		}
+	})
+
+	// 跨字段校验
+	errs = append(errs, conf.RedisCfg.validate("redis")...)
+	errs = append(errs, conf.MonitorCfg.validate("monitor")...)
+	errs = append(errs, validateServices(conf.Services)...)
+	errs = append(errs, validateFlags(conf.FlagsCfg.Items)...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkRule(rule string, value reflect.Value) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	if name == "required" {
+		if value.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+
+	// 其他规则只校验已设置的值
+	if value.IsZero() {
+		return ""
+	}
+
+	switch name {
+	case "url":
+		u, err := url.Parse(value.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Sprintf("%q is not a valid url", value.String())
+		}
+	case "hostport":
+		if _, port, err := net.SplitHostPort(value.String()); err != nil || port == "" {
+			return fmt.Sprintf("%q is not a valid host:port address", value.String())
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Sprintf("bad rule %q", rule)
+		}
+		number, ok := numberOf(value)
+		if !ok {
+			return fmt.Sprintf("rule %q on non numeric value", rule)
+		}
+		if name == "min" && number < limit {
+			return fmt.Sprintf("%v is less than %s", number, arg)
+		}
+		if name == "max" && number > limit {
+			return fmt.Sprintf("%v is greater than %s", number, arg)
+		}
+	case "oneof":
+		options := strings.Fields(arg)
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if actual == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q must be one of %s", actual, strings.Join(options, ", "))
+	default:
+		return fmt.Sprintf("unknown rule %q", rule)
+	}
+
+	return ""
+}
+
+func numberOf(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
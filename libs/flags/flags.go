@@ -0,0 +1,278 @@
+package flags
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"init-golang/libs/config"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Any 作用于全部服务或全部交易对
+const Any = ""
+
+// anyLabel 监控和管理接口中 Any 的显示值
+const anyLabel = "*"
+
+// Key 开关的作用范围
+type Key struct {
+	Name    string
+	Service string
+	Symbol  string
+}
+
+// Flag 功能开关
+type Flag struct {
+	Name    string `json:"name"`
+	Service string `json:"service,omitempty"` // 为空对全部服务生效
+	Symbol  string `json:"symbol,omitempty"`  // 为空对全部交易对生效
+	Enabled bool   `json:"enabled"`
+	Percent *int   `json:"percent,omitempty"` // 灰度比例, 为空是全量, 0 为关闭
+}
+
+// Key 开关的作用范围
+func (f Flag) Key() Key {
+	return Key{Name: f.Name, Service: f.Service, Symbol: f.Symbol}
+}
+
+// Rollout 生效比例, 关闭为 0, 全量为 100, 未设置 Percent 时为全量
+func (f Flag) Rollout() int {
+	switch {
+	case !f.Enabled:
+		return 0
+	case f.Percent == nil || *f.Percent >= 100:
+		return 100
+	case *f.Percent <= 0:
+		return 0
+	}
+	return *f.Percent
+}
+
+// equal 开关内容相同, Percent 按值比较
+func (f Flag) equal(other Flag) bool {
+	if f.Key() != other.Key() || f.Enabled != other.Enabled || (f.Percent == nil) != (other.Percent == nil) {
+		return false
+	}
+	return f.Percent == nil || *f.Percent == *other.Percent
+}
+
+// FromConfig 配置文件中的开关
+func FromConfig(items []config.FeatureFlag) []Flag {
+	flags := make([]Flag, 0, len(items))
+	for _, item := range items {
+		flags = append(flags, Flag{
+			Name:    item.Name,
+			Service: item.Service,
+			Symbol:  item.Symbol,
+			Enabled: item.Enabled,
+			Percent: item.Percent,
+		})
+	}
+	return flags
+}
+
+// Store 功能开关的内存缓存, 合并多个数据源, 后设置的数据源优先
+type Store struct {
+	mu          sync.RWMutex
+	order       []string
+	layers      map[string][]Flag
+	flags       map[Key]Flag
+	subscribers []func(old, new Flag)
+	metrics     *config.Metrics
+}
+
+// NewStore 创建空的开关缓存
+func NewStore() *Store {
+	return &Store{
+		layers: make(map[string][]Flag),
+		flags:  make(map[Key]Flag),
+	}
+}
+
+// Default 默认开关缓存
+var Default = NewStore()
+
+// Set 替换数据源 source 的全部开关并通知变化, 新出现的数据源优先级高于已有的数据源
+func (s *Store) Set(source string, flags []Flag) {
+	s.mu.Lock()
+	if _, ok := s.layers[source]; !ok {
+		s.order = append(s.order, source)
+	}
+	s.layers[source] = flags
+
+	merged := make(map[Key]Flag)
+	for _, name := range s.order {
+		for _, flag := range s.layers[name] {
+			merged[flag.Key()] = flag
+		}
+	}
+
+	var changes [][2]Flag
+	for key, flag := range merged {
+		if old, ok := s.flags[key]; !ok || !old.equal(flag) {
+			changes = append(changes, [2]Flag{old, flag})
+		}
+	}
+	for key, old := range s.flags {
+		if _, ok := merged[key]; !ok {
+			// 删除的开关视为关闭
+			changes = append(changes, [2]Flag{old, {Name: key.Name, Service: key.Service, Symbol: key.Symbol}})
+		}
+	}
+
+	s.flags = merged
+	subscribers := s.subscribers
+	metrics := s.metrics
+	s.mu.Unlock()
+
+	for _, change := range changes {
+		if metrics != nil {
+			metrics.SetFlagValue(label(change[1].Service), change[1].Name, label(change[1].Symbol), change[1].Rollout())
+		}
+		for _, f := range subscribers {
+			f(change[0], change[1])
+		}
+	}
+}
+
+// OnChange 注册开关变化通知, 删除的开关以关闭状态通知
+func (s *Store) OnChange(f func(old, new Flag)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, f)
+}
+
+// SetMetrics 开关变化时更新 feature_flag_percent, 并立即上报当前开关
+func (s *Store) SetMetrics(metrics *config.Metrics) {
+	s.mu.Lock()
+	s.metrics = metrics
+	flags := s.list()
+	s.mu.Unlock()
+
+	for _, flag := range flags {
+		metrics.SetFlagValue(label(flag.Service), flag.Name, label(flag.Symbol), flag.Rollout())
+	}
+}
+
+// Lookup 按 服务+交易对, 服务, 交易对, 全部 的顺序查找最匹配的开关
+func (s *Store) Lookup(name, service, symbol string) (Flag, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range []Key{
+		{name, service, symbol},
+		{name, service, Any},
+		{name, Any, symbol},
+		{name, Any, Any},
+	} {
+		if flag, ok := s.flags[key]; ok {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Percent 开关对 service 和 symbol 的生效比例, 未配置时为 0
+func (s *Store) Percent(name, service, symbol string) int {
+	flag, _ := s.Lookup(name, service, symbol)
+	return flag.Rollout()
+}
+
+// Enabled 开关是否对 service 和 symbol 开启.
+// 灰度开关按 名称+服务+交易对 的哈希分桶, 同一服务和交易对的结果是稳定的.
+func (s *Store) Enabled(name, service, symbol string) bool {
+	percent := s.Percent(name, service, symbol)
+	if percent >= 100 || percent <= 0 {
+		return percent >= 100
+	}
+	return bucket(name, service, symbol) < uint32(percent)
+}
+
+// List 全部开关, 按名称, 服务, 交易对排序
+func (s *Store) List() []Flag {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.list()
+}
+
+func (s *Store) list() []Flag {
+	flags := make([]Flag, 0, len(s.flags))
+	for _, flag := range s.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		a, b := flags[i], flags[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Symbol < b.Symbol
+	})
+	return flags
+}
+
+// Handler 管理接口, 输出全部开关及生效比例.
+// 指定 ?name=&service=&symbol= 时输出该开关对服务和交易对的判断结果.
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+
+		if name := query.Get("name"); name != "" {
+			service, symbol := query.Get("service"), query.Get("symbol")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"name":    name,
+				"service": service,
+				"symbol":  symbol,
+				"percent": s.Percent(name, service, symbol),
+				"enabled": s.Enabled(name, service, symbol),
+			})
+			return
+		}
+
+		type item struct {
+			Flag
+			Service string `json:"service"`
+			Symbol  string `json:"symbol"`
+			Rollout int    `json:"rollout"`
+		}
+		items := []item{}
+		for _, flag := range s.List() {
+			items = append(items, item{Flag: flag, Service: label(flag.Service), Symbol: label(flag.Symbol), Rollout: flag.Rollout()})
+		}
+		json.NewEncoder(w).Encode(items)
+	})
+}
+
+// Enabled 默认开关缓存中的开关是否对 service 和 symbol 开启
+func Enabled(name, service, symbol string) bool {
+	return Default.Enabled(name, service, symbol)
+}
+
+// Percent 默认开关缓存中开关对 service 和 symbol 的生效比例
+func Percent(name, service, symbol string) int {
+	return Default.Percent(name, service, symbol)
+}
+
+// OnChange 注册默认开关缓存的变化通知
+func OnChange(f func(old, new Flag)) {
+	Default.OnChange(f)
+}
+
+func bucket(name, service, symbol string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(name + "|" + service + "|" + symbol))
+	return h.Sum32() % 100
+}
+
+func label(scope string) string {
+	if scope == Any {
+		return anyLabel
+	}
+	return scope
+}
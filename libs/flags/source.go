@@ -0,0 +1,161 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"init-golang/libs/config"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// ConfigSource 配置文件数据源名称
+const ConfigSource = "config"
+
+// Source 开关数据源
+type Source interface {
+	Name() string
+	Load(ctx context.Context) ([]Flag, error)
+}
+
+// MySQLSource 从 MySQL 表读取开关: name, service_id, symbol, enabled, percent
+type MySQLSource struct {
+	DB    *gorm.DB
+	Table string
+}
+
+func (src *MySQLSource) Name() string {
+	return "mysql:" + src.Table
+}
+
+func (src *MySQLSource) Load(ctx context.Context) ([]Flag, error) {
+	var rows []struct {
+		Name      string
+		ServiceID string
+		Symbol    string
+		Enabled   bool
+		Percent   *int // NULL 为全量, 0 为关闭
+	}
+	err := src.DB.WithContext(ctx).
+		Table(src.Table).
+		Select("name", "service_id", "symbol", "enabled", "percent").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make([]Flag, 0, len(rows))
+	for _, row := range rows {
+		flags = append(flags, Flag{
+			Name:    row.Name,
+			Service: row.ServiceID,
+			Symbol:  row.Symbol,
+			Enabled: row.Enabled,
+			Percent: row.Percent,
+		})
+	}
+	return flags, nil
+}
+
+// RedisSource 从 Redis hash 读取开关, 每个 field 的值为一个开关的 json,
+// 如 {"name":"new_pricing","service":"example","enabled":true,"percent":20}
+type RedisSource struct {
+	Client redis.UniversalClient
+	Key    string
+}
+
+func (src *RedisSource) Name() string {
+	return "redis:" + src.Key
+}
+
+func (src *RedisSource) Load(ctx context.Context) ([]Flag, error) {
+	values, err := src.Client.HGetAll(ctx, src.Key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := make([]Flag, 0, len(values))
+	for field, value := range values {
+		var flag Flag
+		if err := json.Unmarshal([]byte(value), &flag); err != nil {
+			return nil, fmt.Errorf("field %s: %s", field, err)
+		}
+		if flag.Name == "" {
+			return nil, fmt.Errorf("field %s: empty flag name", field)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+// NewSource 根据配置创建 MySQL 或 Redis 数据源, 未启用时返回 nil
+func NewSource(cfg config.Flags, db *gorm.DB, client redis.UniversalClient, prefix string) (Source, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "mysql":
+		table := cfg.Table
+		if table == "" {
+			table = "feature_flag"
+		}
+		return &MySQLSource{DB: db, Table: table}, nil
+	case "redis":
+		key := cfg.Key
+		if key == "" {
+			key = prefix + "flags"
+		}
+		return &RedisSource{Client: client, Key: key}, nil
+	}
+	return nil, fmt.Errorf("unknown feature flag provider %q", cfg.Provider)
+}
+
+// Poll 立即加载数据源, 之后按 interval 定期拉取, 拉取失败时保留上次的开关
+func (s *Store) Poll(ctx context.Context, src Source, interval time.Duration) error {
+	flags, err := src.Load(ctx)
+	if err != nil {
+		return err
+	}
+	s.Set(src.Name(), flags)
+
+	if interval <= 0 {
+		interval = time.Second * 10
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			flags, err := src.Load(ctx)
+			if err != nil {
+				log.Printf("feature flags %s: %s", src.Name(), err)
+				continue
+			}
+			s.Set(src.Name(), flags)
+		}
+	}()
+
+	return nil
+}
+
+// Setup 加载配置文件中的开关并跟随配置热更新, 配置了 provider 时再从 MySQL 或 Redis 定期拉取
+func (s *Store) Setup(ctx context.Context, conf *config.Config, db *gorm.DB, client redis.UniversalClient) error {
+	s.Set(ConfigSource, FromConfig(conf.FlagsCfg.Items))
+	config.OnChange(func(old, new *config.Config) {
+		s.Set(ConfigSource, FromConfig(new.FlagsCfg.Items))
+	})
+
+	src, err := NewSource(conf.FlagsCfg, db, client, conf.RedisCfg.PreFix)
+	if err != nil || src == nil {
+		return err
+	}
+	return s.Poll(ctx, src, conf.FlagsCfg.Interval)
+}
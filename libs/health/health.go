@@ -0,0 +1,244 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const (
+	// LivePath 存活检查, 进程能处理请求即返回 200
+	LivePath = "/healthz"
+	// ReadyPath 就绪检查, 全部检查通过返回 200, 否则返回 503
+	ReadyPath = "/readyz"
+)
+
+// defaultTimeout 单项检查的超时时间
+const defaultTimeout = time.Second * 2
+
+// Check 就绪检查项, 返回 nil 表示就绪
+type Check func(ctx context.Context) error
+
+// entry 检查项, optional 的检查项失败时只输出结果, 不影响就绪状态
+type entry struct {
+	check    Check
+	optional bool
+}
+
+// Checker 就绪检查集合, 检查项可以在运行中添加和移除
+type Checker struct {
+	mu      sync.RWMutex
+	checks  map[string]entry
+	Timeout time.Duration // 单项检查的超时时间, 默认 2s
+}
+
+// NewChecker 创建空的检查集合
+func NewChecker() *Checker {
+	return &Checker{checks: make(map[string]entry)}
+}
+
+// Default 默认检查集合
+var Default = NewChecker()
+
+// Add 添加或替换名称为 name 的检查项
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = entry{check: check}
+}
+
+// AddOptional 添加或替换只报告结果的检查项, 失败时 /readyz 仍然返回就绪
+func (c *Checker) AddOptional(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checks[name] = entry{check: check, optional: true}
+}
+
+// Remove 移除名称为 name 的检查项
+func (c *Checker) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.checks, name)
+}
+
+// RemovePrefix 移除名称以 prefix 开头的检查项, 用于服务停止时移除其全部检查
+func (c *Checker) RemovePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name := range c.checks {
+		if strings.HasPrefix(name, prefix) {
+			delete(c.checks, name)
+		}
+	}
+}
+
+// Run 并发执行全部检查, 返回 名称 -> ok 或错误信息, 必需的检查全部通过时 ready 为 true
+func (c *Checker) Run(ctx context.Context) (map[string]string, bool) {
+	c.mu.RLock()
+	checks := make(map[string]entry, len(c.checks))
+	for name, e := range c.checks {
+		checks[name] = e
+	}
+	c.mu.RUnlock()
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]string, len(checks))
+	ready := true
+	for name, e := range checks {
+		wg.Add(1)
+		go func(name string, e entry) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			err := run(ctx, e.check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				results[name] = err.Error()
+				if !e.optional {
+					ready = false
+				}
+			} else {
+				results[name] = "ok"
+			}
+		}(name, e)
+	}
+	wg.Wait()
+
+	return results, ready
+}
+
+// run 执行检查, 检查不响应 ctx 时按超时处理, panic 视为失败
+func run(ctx context.Context, check Check) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ReadyHandler 就绪检查接口, 输出各检查项结果, 未就绪时返回 503.
+// 指定 ?check=name 时只输出该检查项.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		results, ready := c.Run(r.Context())
+		if name := r.URL.Query().Get("check"); name != "" {
+			result, ok := results[name]
+			if !ok {
+				http.Error(w, "unknown check "+name, http.StatusNotFound)
+				return
+			}
+			results = map[string]string{name: result}
+			ready = result == "ok"
+		}
+
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ready":  ready,
+			"checks": results,
+		})
+	})
+}
+
+// LiveHandler 存活检查接口, 只要进程能处理请求即返回 200
+func LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// Register 在 mux 上注册 /healthz 和默认检查集合的 /readyz
+func Register(mux *http.ServeMux) {
+	mux.Handle(LivePath, LiveHandler())
+	mux.Handle(ReadyPath, Default.ReadyHandler())
+}
+
+// Add 向默认检查集合添加检查项
+func Add(name string, check Check) {
+	Default.Add(name, check)
+}
+
+// AddOptional 向默认检查集合添加只报告结果的检查项
+func AddOptional(name string, check Check) {
+	Default.AddOptional(name, check)
+}
+
+// Remove 从默认检查集合移除检查项
+func Remove(name string) {
+	Default.Remove(name)
+}
+
+// MySQL 数据库 ping 检查
+func MySQL(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return errors.New("not connected")
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// Redis 缓存 ping 检查
+func Redis(client redis.UniversalClient) Check {
+	return func(ctx context.Context) error {
+		if client == nil {
+			return errors.New("not connected")
+		}
+		return client.Ping(ctx).Err()
+	}
+}
+
+// Fresh 时效检查, last 返回的时间距今超过 window 时失败, 如远程配置最近一次成功拉取的时间
+func Fresh(last func() time.Time, window time.Duration) Check {
+	return func(ctx context.Context) error {
+		at := last()
+		if at.IsZero() {
+			return errors.New("never synced")
+		}
+		if age := time.Since(at); age > window {
+			return fmt.Errorf("last synced %s ago, exceeds %s", age.Truncate(time.Second), window)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,43 @@
+package httpclient
+
+import (
+	"errors"
+	"init-golang/libs/config"
+	"net/http"
+)
+
+// ErrDisabled 平台服务配置未启用或未配置 pub_url
+var ErrDisabled = errors.New("market api disabled")
+
+// New 按平台服务配置创建 http.Client: 超时取 RequestTimeout, 代理取 ProxyURL,
+// 请求记入 mtx 并以 Trace 级别写入 APILogger(svc).
+//
+//	client, err := httpclient.New(svc, "spot", conf.MarketAPICfg.Spot, mtx)
+//	req, _ := http.NewRequest(http.MethodGet, conf.MarketAPICfg.Spot.PubURL+"/api/v1/orders/"+id, nil)
+//	resp, err := client.Do(httpclient.WithRoute(req, "/api/v1/orders/{id}"))
+func New(svc string, venue string, cfg config.MarketURL, mtx *config.Metrics) (*http.Client, error) {
+	if !cfg.Active() {
+		return nil, ErrDisabled
+	}
+
+	proxy, err := cfg.Proxy()
+	if err != nil {
+		return nil, err
+	}
+
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		base.Proxy = http.ProxyURL(proxy)
+	}
+
+	return &http.Client{
+		Timeout: cfg.RequestTimeout(),
+		Transport: &Transport{
+			Base:    base,
+			Service: svc,
+			Venue:   venue,
+			Metrics: mtx,
+			Logger:  config.APILogger(svc),
+		},
+	}, nil
+}
@@ -0,0 +1,303 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"init-golang/libs/config"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultBodyLog Trace 日志中请求和返回内容的最大长度
+const defaultBodyLog = 2048
+
+// maxBodyParse 请求内容超过该长度时不解析, 不输出
+const maxBodyParse = 1 << 20
+
+// sensitiveParams Trace 日志中隐藏的查询参数, 表单参数和 JSON 字段, 比较时忽略大小写
+var sensitiveParams = map[string]bool{
+	"apikey":     true,
+	"api_key":    true,
+	"key":        true,
+	"secret":     true,
+	"sign":       true,
+	"signature":  true,
+	"token":      true,
+	"passphrase": true,
+}
+
+// idSegment 路径中的 ID: 纯数字, UUID 或较长的十六进制串
+var idSegment = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+type routeKey struct{}
+
+// WithRoute 指定请求的路由模板, 如 /api/v1/orders/{id}, 作为 api 标签, 避免 URL 中的 ID 造成标签值过多
+func WithRoute(req *http.Request, route string) *http.Request {
+	return req.WithContext(RouteContext(req.Context(), route))
+}
+
+// RouteContext 在 ctx 中记录路由模板
+func RouteContext(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// StatusError 服务端返回 4xx, 5xx
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http status %d", e.Code)
+}
+
+// 按状态码分类为 http_4xx, http_429 和 http_5xx, 限流单独统计
+func init() {
+	config.RegisterErrorRule(func(err error) (string, bool) {
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			return "", false
+		}
+		switch {
+		case statusErr.Code == http.StatusTooManyRequests:
+			return "http_429", true
+		case statusErr.Code >= http.StatusInternalServerError:
+			return "http_5xx", true
+		}
+		return "http_4xx", true
+	})
+}
+
+// Transport 记录请求计数, 耗时和错误类型的 http.RoundTripper.
+//
+// api 标签为 `<Venue> <METHOD> <路由模板>`, 路由模板由 WithRoute 指定, 未指定时把路径中的 ID 替换为 {id}.
+// Logger 开启 Trace 级别时输出请求和返回, 查询参数, 表单和 JSON 中的密钥被隐藏, 其他类型的请求内容不输出,
+// 返回内容在调用方读取时记录.
+type Transport struct {
+	Base    http.RoundTripper // 为空使用 http.DefaultTransport
+	Service string
+	Venue   string
+	Metrics *config.Metrics
+	Logger  *config.CFLogger
+
+	// MaxBodyLog Trace 日志中内容的最大长度, 默认 2048, 小于 0 不输出内容
+	MaxBodyLog int
+}
+
+// RoundTrip 执行请求并记录 metrics 和日志
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	api := t.api(req)
+	trace := t.Logger != nil && t.Logger.IsTraceEnabled()
+	if trace {
+		t.Logger.Trace("%s request %s %s %s", api, req.Method, redactURL(req.URL), t.peekRequest(req))
+	}
+
+	begin := time.Now()
+	resp, err := base.RoundTrip(req)
+
+	labelErr := err
+	if err == nil && resp.StatusCode >= http.StatusBadRequest {
+		labelErr = &StatusError{Code: resp.StatusCode}
+	}
+	if t.Metrics != nil {
+		t.Metrics.AddApiCounter(t.Service, api, labelErr)
+		t.Metrics.SetApiSummary(t.Service, api, labelErr, begin)
+	}
+
+	if trace {
+		if err != nil {
+			t.Logger.Trace("%s failed after %s: %s", api, time.Since(begin), err)
+		} else {
+			t.Logger.Trace("%s response %d after %s", api, resp.StatusCode, time.Since(begin))
+			t.traceResponse(api, resp)
+		}
+	}
+
+	return resp, err
+}
+
+func (t *Transport) api(req *http.Request) string {
+	route, _ := req.Context().Value(routeKey{}).(string)
+	if route == "" {
+		route = RouteTemplate(req.URL.Path)
+	}
+
+	api := req.Method + " " + route
+	if t.Venue != "" {
+		api = t.Venue + " " + api
+	}
+	return api
+}
+
+func (t *Transport) bodyLimit() int {
+	if t.MaxBodyLog == 0 {
+		return defaultBodyLog
+	}
+	return t.MaxBodyLog
+}
+
+// peekRequest 读取请求内容用于日志, 表单和 JSON 中的密钥和签名被隐藏, 其他类型不输出内容, 请求 Body 不受影响
+func (t *Transport) peekRequest(req *http.Request) string {
+	if req.Body == nil || req.GetBody == nil || t.bodyLimit() < 0 {
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if mediaType != "application/x-www-form-urlencoded" && !isJSON {
+		return "<body omitted>"
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+
+	// 完整读取后再隐藏密钥, 截断的内容无法解析
+	data, _ := io.ReadAll(io.LimitReader(body, maxBodyParse+1))
+	if len(data) > maxBodyParse {
+		return "<body too large>"
+	}
+
+	var redacted string
+	if isJSON {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return "<json omitted>"
+		}
+		encoded, err := json.Marshal(redactJSON(value))
+		if err != nil {
+			return "<json omitted>"
+		}
+		redacted = string(encoded)
+	} else {
+		form, err := url.ParseQuery(string(data))
+		if err != nil {
+			return "<form omitted>"
+		}
+		redacted = redactValues(form).Encode()
+	}
+
+	if len(redacted) > t.bodyLimit() {
+		redacted = redacted[:t.bodyLimit()]
+	}
+	return redacted
+}
+
+// traceResponse 调用方读取返回内容时记录开头部分, 读完或关闭时输出, 不阻塞 RoundTrip
+func (t *Transport) traceResponse(api string, resp *http.Response) {
+	if resp.Body == nil || resp.Body == http.NoBody || t.bodyLimit() < 0 {
+		return
+	}
+	resp.Body = &traceBody{
+		ReadCloser: resp.Body,
+		limit:      t.bodyLimit(),
+		log: func(body string) {
+			t.Logger.Trace("%s response body %s", api, body)
+		},
+	}
+}
+
+// traceBody 记录已读取内容的开头, 第一次读到 EOF 或关闭时输出
+type traceBody struct {
+	io.ReadCloser
+	limit  int
+	head   bytes.Buffer
+	logged sync.Once
+	log    func(body string)
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if remain := b.limit - b.head.Len(); remain > 0 && n > 0 {
+		if remain > n {
+			remain = n
+		}
+		b.head.Write(p[:remain])
+	}
+	if err == io.EOF {
+		b.flush()
+	}
+	return n, err
+}
+
+func (b *traceBody) Close() error {
+	b.flush()
+	return b.ReadCloser.Close()
+}
+
+func (b *traceBody) flush() {
+	b.logged.Do(func() {
+		b.log(b.head.String())
+	})
+}
+
+// RouteTemplate 把路径中的数字, UUID 和十六进制 ID 替换为 {id}
+func RouteTemplate(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// redactURL 隐藏查询参数中的密钥和签名
+func redactURL(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+
+	redacted := *u
+	redacted.RawQuery = redactValues(u.Query()).Encode()
+	return redacted.String()
+}
+
+// redactJSON 隐藏 JSON 对象中名称为密钥和签名的字段, 包括嵌套的对象和数组
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for name, item := range v {
+			if sensitiveParams[strings.ToLower(name)] {
+				v[name] = config.SecretMask
+			} else {
+				v[name] = redactJSON(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+	return value
+}
+
+// redactValues 隐藏查询参数或表单中的密钥和签名
+func redactValues(values url.Values) url.Values {
+	for name := range values {
+		if sensitiveParams[strings.ToLower(name)] {
+			values.Set(name, config.SecretMask)
+		}
+	}
+	return values
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package logger
+
+import "errors"
+
+// diskFree is not implemented on windows, the disk guard stays in DiskOK.
+func diskFree(dir string) (uint64, error) {
+	return 0, errors.New("disk free space not supported on windows")
+}
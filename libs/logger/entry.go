@@ -168,7 +168,16 @@ func (entry *Entry) write() {
 		fmt.Fprintf(os.Stderr, "Failed to obtain reader, %v\n", err)
 		return
 	}
-	if _, err = entry.Logger.Out.Write(serialized); err != nil {
+	// formatters may skip entries they do not handle
+	if len(serialized) == 0 {
+		return
+	}
+	if lw, ok := entry.Logger.Out.(LevelWriter); ok {
+		_, err = lw.WriteLevel(entry.Level, serialized)
+	} else {
+		_, err = entry.Logger.Out.Write(serialized)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
 	}
 }
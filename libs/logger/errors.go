@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Suffixes of the extra keys rendered next to an error field,
+// e.g. `error.type`, `error.chain` and `error.stack`.
+const (
+	ErrorTypeSuffix  = ".type"
+	ErrorChainSuffix = ".chain"
+	ErrorStackSuffix = ".stack"
+)
+
+// ErrorCause 错误链中的单个节点
+type ErrorCause struct {
+	Type    string `json:"type"`
+	Message string `json:"msg"`
+}
+
+// String renders the cause as `type: msg`.
+func (c ErrorCause) String() string {
+	return c.Type + ": " + c.Message
+}
+
+// stackError error with the stack captured by WithStack
+type stackError struct {
+	err   error
+	stack []uintptr
+}
+
+// WithStack annotates err with the stack trace at the point WithStack was called.
+// It returns nil if err is nil.
+func WithStack(err error) error {
+	if err == nil {
+		return nil
+	}
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	return &stackError{err: err, stack: pcs[:n]}
+}
+
+func (e *stackError) Error() string { return e.err.Error() }
+
+func (e *stackError) Unwrap() error { return e.err }
+
+// Stack returns the formatted stack trace, one `function\n\tfile:line` pair per frame.
+func (e *stackError) Stack() []byte {
+	var b strings.Builder
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return []byte(b.String())
+}
+
+// unwrapError returns the next error in the chain. Besides the standard
+// `Unwrap() error` it understands `Unwrap() []error` (errors.Join) and the
+// `Cause() error` convention of github.com/pkg/errors.
+func unwrapError(err error) error {
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	case interface{ Unwrap() []error }:
+		if errs := e.Unwrap(); len(errs) > 0 {
+			return errs[0]
+		}
+	case interface{ Cause() error }:
+		return e.Cause()
+	}
+	return nil
+}
+
+// ErrorTypeName returns the dynamic type name of err, e.g. `*fs.PathError`.
+func ErrorTypeName(err error) string {
+	if err == nil {
+		return ""
+	}
+	return reflect.TypeOf(err).String()
+}
+
+// RootCause returns the innermost error of the chain.
+func RootCause(err error) error {
+	for err != nil {
+		next := unwrapError(err)
+		if next == nil {
+			return err
+		}
+		err = next
+	}
+	return nil
+}
+
+// ErrorChain unwraps err into a list ordered from the outermost error to the root cause.
+func ErrorChain(err error) []ErrorCause {
+	var chain []ErrorCause
+	for ; err != nil; err = unwrapError(err) {
+		chain = append(chain, ErrorCause{Type: ErrorTypeName(err), Message: err.Error()})
+	}
+	return chain
+}
+
+// ErrorStack returns the first stack trace carried by an error in the chain.
+// Errors created by WithStack and errors exposing a `StackTrace()` method
+// (github.com/pkg/errors) are supported. It returns "" if there is none.
+func ErrorStack(err error) string {
+	for ; err != nil; err = unwrapError(err) {
+		if st, ok := err.(interface{ Stack() []byte }); ok {
+			return string(st.Stack())
+		}
+		// pkg/errors returns its own StackTrace type, so the method is looked up by name
+		method := reflect.ValueOf(err).MethodByName("StackTrace")
+		if method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() == 1 {
+			return strings.TrimPrefix(fmt.Sprintf("%+v", method.Call(nil)[0].Interface()), "\n")
+		}
+	}
+	return ""
+}
+
+// errorFields 展开错误字段: 类型, 错误链以及堆栈.
+// structured 为 true 时错误链保持为列表, 否则转换为字符串.
+func errorFields(key string, err error, structured bool) Fields {
+	fields := Fields{key + ErrorTypeSuffix: ErrorTypeName(err)}
+
+	if chain := ErrorChain(err); len(chain) > 1 {
+		if structured {
+			fields[key+ErrorChainSuffix] = chain
+		} else {
+			types := make([]string, 0, len(chain))
+			for _, cause := range chain {
+				types = append(types, cause.Type)
+			}
+			fields[key+ErrorChainSuffix] = strings.Join(types, " > ")
+		}
+	}
+
+	if stack := ErrorStack(err); stack != "" {
+		fields[key+ErrorStackSuffix] = stack
+	}
+
+	return fields
+}
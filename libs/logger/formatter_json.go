@@ -47,6 +47,10 @@ type FormatterJSON struct {
 
 	// PrettyPrint will indent all json logs
 	PrettyPrint bool
+
+	// DisableErrorChain disables the `<key>.type`, `<key>.chain` and `<key>.stack`
+	// fields rendered next to error values
+	DisableErrorChain bool
 }
 
 // Format renders a single log entry
@@ -58,6 +62,11 @@ func (f *FormatterJSON) Format(entry *Entry) ([]byte, error) {
 			// Otherwise errors are ignored by `encoding/json`
 			// https://github.com/sirupsen/logrus/issues/137
 			data[k] = v.Error()
+			if !f.DisableErrorChain {
+				for ek, ev := range errorFields(k, v, true) {
+					data[ek] = ev
+				}
+			}
 		default:
 			data[k] = v
 		}
@@ -37,6 +37,9 @@ type FormatterNginx struct {
 
 	// NoUppercaseLevel - no upper case for level value
 	NoUppercaseLevel bool
+
+	// NoErrorChain - do not render [error.type], [error.chain] and [error.stack] next to error fields
+	NoErrorChain bool
 }
 
 // Format an log entry
@@ -83,10 +86,14 @@ func (f *FormatterNginx) Format(entry *Entry) ([]byte, error) {
 	}
 
 	// write fields
+	data := entry.Data
+	if !f.NoErrorChain {
+		data = f.expandErrors(data)
+	}
 	if f.FieldsOrder == nil {
-		f.writeFields(b, entry)
+		f.writeFields(b, data)
 	} else {
-		f.writeOrderedFields(b, entry)
+		f.writeOrderedFields(b, data)
 	}
 
 	if f.NoFieldsSpace {
@@ -101,35 +108,59 @@ func (f *FormatterNginx) Format(entry *Entry) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-func (f *FormatterNginx) writeFields(b *bytes.Buffer, entry *Entry) {
-	if len(entry.Data) != 0 {
-		fields := make([]string, 0, len(entry.Data))
-		for field := range entry.Data {
+// expandErrors copies data with the error values unwrapped, data is returned as is without errors
+func (f *FormatterNginx) expandErrors(data Fields) Fields {
+	var expanded Fields
+	for k, v := range data {
+		err, ok := v.(error)
+		if !ok {
+			continue
+		}
+		if expanded == nil {
+			expanded = make(Fields, len(data)+3)
+			for dk, dv := range data {
+				expanded[dk] = dv
+			}
+		}
+		for ek, ev := range errorFields(k, err, false) {
+			expanded[ek] = ev
+		}
+	}
+	if expanded == nil {
+		return data
+	}
+	return expanded
+}
+
+func (f *FormatterNginx) writeFields(b *bytes.Buffer, data Fields) {
+	if len(data) != 0 {
+		fields := make([]string, 0, len(data))
+		for field := range data {
 			fields = append(fields, field)
 		}
 
 		sort.Strings(fields)
 
 		for _, field := range fields {
-			f.writeField(b, entry, field)
+			f.writeField(b, data, field)
 		}
 	}
 }
 
-func (f *FormatterNginx) writeOrderedFields(b *bytes.Buffer, entry *Entry) {
-	length := len(entry.Data)
+func (f *FormatterNginx) writeOrderedFields(b *bytes.Buffer, data Fields) {
+	length := len(data)
 	foundFieldsMap := map[string]bool{}
 	for _, field := range f.FieldsOrder {
-		if _, ok := entry.Data[field]; ok {
+		if _, ok := data[field]; ok {
 			foundFieldsMap[field] = true
 			length--
-			f.writeField(b, entry, field)
+			f.writeField(b, data, field)
 		}
 	}
 
 	if length > 0 {
 		notFoundFields := make([]string, 0, length)
-		for field := range entry.Data {
+		for field := range data {
 			if foundFieldsMap[field] == false {
 				notFoundFields = append(notFoundFields, field)
 			}
@@ -138,16 +169,16 @@ func (f *FormatterNginx) writeOrderedFields(b *bytes.Buffer, entry *Entry) {
 		sort.Strings(notFoundFields)
 
 		for _, field := range notFoundFields {
-			f.writeField(b, entry, field)
+			f.writeField(b, data, field)
 		}
 	}
 }
 
-func (f *FormatterNginx) writeField(b *bytes.Buffer, entry *Entry, field string) {
+func (f *FormatterNginx) writeField(b *bytes.Buffer, data Fields, field string) {
 	if f.HideKeys {
-		fmt.Fprintf(b, "[%v]", entry.Data[field])
+		fmt.Fprintf(b, "[%v]", data[field])
 	} else {
-		fmt.Fprintf(b, "[%s:%v]", field, entry.Data[field])
+		fmt.Fprintf(b, "[%s:%v]", field, data[field])
 	}
 
 	if !f.NoFieldsSpace {
@@ -0,0 +1,189 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Field keys read by FormatterPriceBinary
+const (
+	FieldKeySymbol = "symbol"
+	FieldKeyPrice  = "price"
+)
+
+// priceRecordVersion version byte of the binary price record layout
+const priceRecordVersion = 1
+
+// priceRecordMaxSize upper bound of a single record, protects the decoder from corrupted length prefixes
+const priceRecordMaxSize = 1 << 16
+
+// PriceRecord 对标价格记录, Price 与 utils.BenchmarkPriceItem 一致: {askPrice, bidPrice, midPrice, wmidPrice, weight}
+type PriceRecord struct {
+	Time   time.Time
+	Symbol string
+	Price  [5]decimal.Decimal
+}
+
+// FormatterPriceBinary formats price ticks into length-prefixed binary records.
+//
+// Price entries carry FieldKeySymbol (string) and FieldKeyPrice
+// (utils.BenchmarkPriceItem), the message and other fields are dropped.
+// Entries without them, e.g. a plain Info call, are skipped so that the
+// binary stream stays decodable.
+// Layout, big endian:
+//
+//	uint32 payload length
+//	uint8  version
+//	int64  unix nano timestamp
+//	uint8  symbol length, symbol
+//	5 x    int32 exponent, uint8 negative, uint8 coefficient length, coefficient
+type FormatterPriceBinary struct{}
+
+// Format renders a single price entry, non-price entries render as nothing
+func (f *FormatterPriceBinary) Format(entry *Entry) ([]byte, error) {
+	symbol, ok := entry.Data[FieldKeySymbol].(string)
+	if !ok {
+		return nil, nil
+	}
+	price, ok := entry.Data[FieldKeyPrice].([5]decimal.Decimal)
+	if !ok {
+		return nil, nil
+	}
+
+	var b *bytes.Buffer
+	if entry.Buffer != nil {
+		b = entry.Buffer
+	} else {
+		b = &bytes.Buffer{}
+	}
+
+	if err := WritePriceRecord(b, &PriceRecord{Time: entry.Time, Symbol: symbol, Price: price}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// WritePriceRecord appends the binary encoding of rec to b.
+func WritePriceRecord(b *bytes.Buffer, rec *PriceRecord) error {
+	if len(rec.Symbol) > 0xff {
+		return fmt.Errorf("symbol %q too long", rec.Symbol)
+	}
+
+	var scratch [8]byte
+	start := b.Len()
+
+	b.Write(scratch[:4]) // length placeholder
+	b.WriteByte(priceRecordVersion)
+	binary.BigEndian.PutUint64(scratch[:], uint64(rec.Time.UnixNano()))
+	b.Write(scratch[:8])
+	b.WriteByte(byte(len(rec.Symbol)))
+	b.WriteString(rec.Symbol)
+
+	for _, value := range rec.Price {
+		coef := value.Coefficient()
+		abs := coef.Bytes()
+		if len(abs) > 0xff {
+			return fmt.Errorf("decimal %s too large", value)
+		}
+
+		binary.BigEndian.PutUint32(scratch[:], uint32(value.Exponent()))
+		b.Write(scratch[:4])
+		if coef.Sign() < 0 {
+			b.WriteByte(1)
+		} else {
+			b.WriteByte(0)
+		}
+		b.WriteByte(byte(len(abs)))
+		b.Write(abs)
+	}
+
+	binary.BigEndian.PutUint32(b.Bytes()[start:], uint32(b.Len()-start-4))
+	return nil
+}
+
+// PriceDecoder reads records written by FormatterPriceBinary
+type PriceDecoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewPriceDecoder returns a decoder reading from r
+func NewPriceDecoder(r io.Reader) *PriceDecoder {
+	return &PriceDecoder{r: bufio.NewReader(r)}
+}
+
+// Next decodes the next record, io.EOF is returned at the end of the stream.
+func (d *PriceDecoder) Next() (*PriceRecord, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(size[:])
+	if length > priceRecordMaxSize {
+		return nil, fmt.Errorf("price record of %d bytes exceeds limit", length)
+	}
+	if cap(d.buf) < int(length) {
+		d.buf = make([]byte, length)
+	}
+	payload := d.buf[:length]
+	if _, err := io.ReadFull(d.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return decodePriceRecord(payload)
+}
+
+var errShortPriceRecord = errors.New("short price record")
+
+func decodePriceRecord(p []byte) (*PriceRecord, error) {
+	if len(p) < 10 {
+		return nil, errShortPriceRecord
+	}
+	if p[0] != priceRecordVersion {
+		return nil, fmt.Errorf("unknown price record version %d", p[0])
+	}
+
+	rec := &PriceRecord{Time: time.Unix(0, int64(binary.BigEndian.Uint64(p[1:9])))}
+
+	n := int(p[9])
+	p = p[10:]
+	if len(p) < n {
+		return nil, errShortPriceRecord
+	}
+	rec.Symbol = string(p[:n])
+	p = p[n:]
+
+	for i := range rec.Price {
+		if len(p) < 6 {
+			return nil, errShortPriceRecord
+		}
+		exp := int32(binary.BigEndian.Uint32(p[:4]))
+		negative := p[4] == 1
+		n = int(p[5])
+		p = p[6:]
+		if len(p) < n {
+			return nil, errShortPriceRecord
+		}
+
+		coef := new(big.Int).SetBytes(p[:n])
+		if negative {
+			coef.Neg(coef)
+		}
+		rec.Price[i] = decimal.NewFromBigInt(coef, exp)
+		p = p[n:]
+	}
+
+	return rec, nil
+}
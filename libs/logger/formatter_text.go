@@ -74,6 +74,10 @@ type FormatterText struct {
 	// QuoteEmptyFields will wrap empty fields in quotes if true
 	QuoteEmptyFields bool
 
+	// DisableErrorChain disables the `<key>.type`, `<key>.chain` and `<key>.stack`
+	// fields rendered next to error values
+	DisableErrorChain bool
+
 	// Whether the logger's out is to a terminal
 	isTerminal bool
 
@@ -125,6 +129,11 @@ func (f *FormatterText) Format(entry *Entry) ([]byte, error) {
 	data := make(Fields)
 	for k, v := range entry.Data {
 		data[k] = v
+		if err, ok := v.(error); ok && !f.DisableErrorChain {
+			for ek, ev := range errorFields(k, err, false) {
+				data[ek] = ev
+			}
+		}
 	}
 	prefixFieldClashes(data, f.FieldMap)
 	keys := make([]string, 0, len(data))
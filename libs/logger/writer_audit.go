@@ -0,0 +1,399 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditGenesis prev hash of the first record in an audit file
+var auditGenesis = strings.Repeat("0", sha256.Size*2)
+
+// AuditHeadSuffix suffix of the file holding the latest signed checkpoint, e.g. `audit.log.head`
+const AuditHeadSuffix = ".head"
+
+// auditRecord 审计日志单行记录, 普通记录带 Data, 检查点记录带 Checkpoint 和 Sig
+type auditRecord struct {
+	Seq        uint64 `json:"seq"`
+	Prev       string `json:"prev"`
+	Data       string `json:"data,omitempty"`
+	Checkpoint string `json:"checkpoint,omitempty"`
+	Sig        string `json:"sig,omitempty"`
+}
+
+// WriterAudit append-only audit log writer.
+//
+// Every write becomes one JSON line carrying the SHA-256 of the previous line,
+// so editing or removing a line breaks the chain. Checkpoint lines signed with
+// HMAC-SHA256 are appended every CheckpointLines records and every
+// CheckpointInterval, the latest one is also copied into `<Filename>.head`
+// so that truncating the tail of the file can be detected by VerifyAuditFile.
+type WriterAudit struct {
+	sync.Mutex
+
+	Filename string
+
+	// Key HMAC key used to sign checkpoints
+	Key []byte
+
+	// CheckpointLines checkpoint after this many records, default 1000
+	CheckpointLines int64
+	// CheckpointInterval checkpoint pending records periodically, default 1 minute
+	CheckpointInterval time.Duration
+
+	FilePerm   string
+	FolderPerm string
+
+	file    *os.File
+	seq     uint64
+	prev    string
+	pending int64
+	done    chan struct{}
+}
+
+// NewAuditFile opens (or continues) the audit file and sets it as the logger output.
+func (logger *Logger) NewAuditFile(writer *WriterAudit) (*WriterAudit, error) {
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	logger.SetOutput(writer)
+	return writer, nil
+}
+
+func (w *WriterAudit) open() error {
+	if len(w.Key) == 0 {
+		return errors.New("audit: empty checkpoint key")
+	}
+	if w.FilePerm == "" {
+		w.FilePerm = "0640"
+	}
+	if w.FolderPerm == "" {
+		w.FolderPerm = "0775"
+	}
+	if w.CheckpointLines <= 0 {
+		w.CheckpointLines = 1000
+	}
+	if w.CheckpointInterval <= 0 {
+		w.CheckpointInterval = time.Minute
+	}
+
+	filePerm, err := strconv.ParseInt(w.FilePerm, 8, 64)
+	if err != nil {
+		return err
+	}
+	folderPerm, err := strconv.ParseInt(w.FolderPerm, 8, 64)
+	if err != nil {
+		return err
+	}
+
+	os.MkdirAll(path.Dir(w.Filename), os.FileMode(folderPerm))
+
+	// continue the chain from the last complete line of an existing file,
+	// the chain must be intact up to that line
+	w.seq, w.prev = 0, auditGenesis
+	err = scanAuditFile(w.Filename, func(line []byte, rec *auditRecord) error {
+		if rec.Seq != w.seq+1 || rec.Prev != w.prev {
+			return fmt.Errorf("seq %d: hash chain broken, run auditverify", rec.Seq)
+		}
+		w.seq = rec.Seq
+		w.prev = hashAuditLine(line)
+		return nil
+	})
+	var torn *auditTornError
+	if errors.As(err, &torn) {
+		// a crash in the middle of a write leaves an incomplete last line,
+		// drop it so that the file can be continued
+		if err := os.Truncate(w.Filename, torn.Offset); err != nil {
+			return fmt.Errorf("audit: truncate %s: %s", w.Filename, err)
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("audit: read %s: %s", w.Filename, err)
+	}
+
+	fd, err := os.OpenFile(w.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.FileMode(filePerm))
+	if err != nil {
+		return err
+	}
+	os.Chmod(w.Filename, os.FileMode(filePerm))
+	w.file = fd
+
+	// record the dropped tail in the chain and seal it
+	if torn != nil {
+		if err := w.sealTorn(torn); err != nil {
+			w.file.Close()
+			w.file = nil
+			return fmt.Errorf("audit: seal %s: %s", w.Filename, err)
+		}
+	}
+
+	w.done = make(chan struct{})
+	go w.checkpointLoop(w.done, w.CheckpointInterval)
+
+	return nil
+}
+
+// sealTorn appends a record describing the dropped incomplete line, followed by a checkpoint
+func (w *WriterAudit) sealTorn(torn *auditTornError) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"level":       "warning",
+		"msg":         "audit: dropped incomplete last line",
+		"time":        time.Now().Format(time.RFC3339Nano),
+		"after_seq":   w.seq,
+		"torn_bytes":  len(torn.Tail),
+		"torn_sha256": hashAuditLine(torn.Tail),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.appendLine(&auditRecord{Data: string(data)}, false); err != nil {
+		return err
+	}
+	return w.checkpoint()
+}
+
+// Write appends p as one chained record.
+func (w *WriterAudit) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	rec := auditRecord{Data: string(bytes.TrimSuffix(p, []byte("\n")))}
+	if _, err := w.appendLine(&rec, false); err != nil {
+		return 0, err
+	}
+
+	w.pending++
+	if w.pending >= w.CheckpointLines {
+		if err := w.checkpoint(); err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Checkpoint writes a signed checkpoint if there are records since the last one.
+func (w *WriterAudit) Checkpoint() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil || w.pending == 0 {
+		return nil
+	}
+	return w.checkpoint()
+}
+
+// Close writes a final checkpoint and closes the file.
+func (w *WriterAudit) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	close(w.done)
+
+	var err error
+	if w.pending > 0 {
+		err = w.checkpoint()
+	}
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
+
+func (w *WriterAudit) checkpointLoop(done chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := w.Checkpoint(); err != nil {
+				fmt.Fprintf(os.Stderr, "WriterAudit(%q): %s\n", w.Filename, err)
+			}
+		}
+	}
+}
+
+func (w *WriterAudit) checkpoint() error {
+	rec := auditRecord{Checkpoint: time.Now().Format(time.RFC3339Nano)}
+	line, err := w.appendLine(&rec, true)
+	if err != nil {
+		return err
+	}
+	w.pending = 0
+
+	// keep the latest checkpoint out of the log file so truncation is visible
+	head := w.Filename + AuditHeadSuffix
+	tmp := head + ".tmp"
+	if err := os.WriteFile(tmp, append(line, '\n'), 0640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, head)
+}
+
+func (w *WriterAudit) appendLine(rec *auditRecord, sign bool) ([]byte, error) {
+	rec.Seq = w.seq + 1
+	rec.Prev = w.prev
+	if sign {
+		rec.Sig = signAuditCheckpoint(w.Key, rec)
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.file.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+
+	w.seq = rec.Seq
+	w.prev = hashAuditLine(line)
+	return line, nil
+}
+
+func hashAuditLine(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+func signAuditCheckpoint(key []byte, rec *auditRecord) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d|%s|%s", rec.Seq, rec.Prev, rec.Checkpoint)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditTornError the last line has no trailing newline, left by a crash during a write
+type auditTornError struct {
+	Offset int64  // size of the file up to the last complete line
+	Tail   []byte // the incomplete line
+}
+
+func (e *auditTornError) Error() string {
+	return fmt.Sprintf("incomplete last line %q", e.Tail)
+}
+
+func scanAuditFile(filename string, f func(line []byte, rec *auditRecord) error) error {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	reader := bufio.NewReader(fd)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if line[len(line)-1] != '\n' {
+				return &auditTornError{Offset: offset, Tail: line}
+			}
+			offset += int64(len(line))
+			line = line[:len(line)-1]
+			rec := &auditRecord{}
+			if jerr := json.Unmarshal(line, rec); jerr != nil {
+				return fmt.Errorf("malformed line %q: %s", line, jerr)
+			}
+			if ferr := f(line, rec); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// AuditReport 审计日志校验结果
+type AuditReport struct {
+	Records     uint64 // 普通记录数
+	Checkpoints uint64 // 检查点数
+	LastSeq     uint64 // 最后一行序号
+	Unsealed    uint64 // 最后一个检查点之后的记录数, 这些记录的截断无法被发现
+}
+
+// VerifyAuditFile checks the hash chain and checkpoint signatures of an audit
+// file written by WriterAudit, and compares it with the `.head` file to detect
+// truncation. The first violation found is returned as error.
+func VerifyAuditFile(filename string, key []byte) (*AuditReport, error) {
+	report := &AuditReport{}
+	prev := auditGenesis
+	hashes := make(map[uint64]string)
+
+	err := scanAuditFile(filename, func(line []byte, rec *auditRecord) error {
+		if rec.Seq != report.LastSeq+1 {
+			return fmt.Errorf("seq %d: expected seq %d, lines removed or reordered", rec.Seq, report.LastSeq+1)
+		}
+		if rec.Prev != prev {
+			return fmt.Errorf("seq %d: hash chain broken, previous line modified", rec.Seq)
+		}
+		if rec.Checkpoint != "" {
+			if !hmac.Equal([]byte(rec.Sig), []byte(signAuditCheckpoint(key, rec))) {
+				return fmt.Errorf("seq %d: invalid checkpoint signature", rec.Seq)
+			}
+			report.Checkpoints++
+			report.Unsealed = 0
+			hashes[rec.Seq] = hashAuditLine(line)
+		} else {
+			report.Records++
+			report.Unsealed++
+		}
+
+		report.LastSeq = rec.Seq
+		prev = hashAuditLine(line)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	head, err := os.ReadFile(filename + AuditHeadSuffix)
+	if os.IsNotExist(err) {
+		if report.LastSeq > 0 {
+			return report, fmt.Errorf("missing %s", filename+AuditHeadSuffix)
+		}
+		return report, nil
+	}
+	if err != nil {
+		return report, err
+	}
+
+	head = bytes.TrimSuffix(head, []byte("\n"))
+	rec := &auditRecord{}
+	if err := json.Unmarshal(head, rec); err != nil {
+		return report, fmt.Errorf("malformed head: %s", err)
+	}
+	if rec.Checkpoint == "" || !hmac.Equal([]byte(rec.Sig), []byte(signAuditCheckpoint(key, rec))) {
+		return report, errors.New("invalid head checkpoint signature")
+	}
+	if rec.Seq > report.LastSeq {
+		return report, fmt.Errorf("file truncated: head checkpoint at seq %d, file ends at seq %d", rec.Seq, report.LastSeq)
+	}
+	if hashes[rec.Seq] != hashAuditLine(head) {
+		return report, fmt.Errorf("seq %d: checkpoint does not match head", rec.Seq)
+	}
+
+	return report, nil
+}
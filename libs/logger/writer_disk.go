@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+// DiskState free space state of a WriterFile
+type DiskState int32
+
+const (
+	// DiskOK enough free space, everything is written
+	DiskOK DiskState = iota
+	// DiskLow below the soft limit, levels under Warn are dropped
+	DiskLow
+	// DiskFull below the hard limit, file writes are paused
+	DiskFull
+)
+
+// String Convert the DiskState to a string.
+func (state DiskState) String() string {
+	switch state {
+	case DiskOK:
+		return "ok"
+	case DiskLow:
+		return "low"
+	case DiskFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// LevelWriter is implemented by outputs that want to filter on the entry level.
+// Entry.write calls WriteLevel instead of Write when Logger.Out implements it.
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level Level, p []byte) (int, error)
+}
+
+// NewLogWriter like NewLogFile, but the logger writes through the returned
+// WriterFile, which keeps working across rotations and applies the disk guard
+// when DiskSoftLimit or DiskHardLimit is set.
+func (logger *Logger) NewLogWriter(writer *WriterFile) io.Writer {
+	writer.wrapped = true
+	logger.NewLogFile(writer)
+	logger.SetOutput(writer)
+
+	if writer.DiskSoftLimit > 0 || writer.DiskHardLimit > 0 {
+		if writer.DiskCheckInterval <= 0 {
+			writer.DiskCheckInterval = 10 * time.Second
+		}
+		writer.checkDisk()
+		go writer.diskGuard()
+	}
+
+	return writer
+}
+
+// Write writes p into the current log file, nothing is written while the disk is full.
+func (w *WriterFile) Write(p []byte) (int, error) {
+	if w.DiskState() == DiskFull {
+		return len(p), nil
+	}
+
+	w.RLock()
+	defer w.RUnlock()
+	if w.FileWriter == nil {
+		return 0, os.ErrClosed
+	}
+	return w.FileWriter.Write(p)
+}
+
+// WriteLevel writes p unless the disk guard drops the level.
+func (w *WriterFile) WriteLevel(level Level, p []byte) (int, error) {
+	if w.DiskState() == DiskLow && level > WarnLevel {
+		return len(p), nil
+	}
+	return w.Write(p)
+}
+
+// DiskState returns the current disk state.
+func (w *WriterFile) DiskState() DiskState {
+	return DiskState(atomic.LoadInt32(&w.diskState))
+}
+
+func (w *WriterFile) diskGuard() {
+	ticker := time.NewTicker(w.DiskCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.checkDisk()
+	}
+}
+
+func (w *WriterFile) checkDisk() {
+	free, err := diskFree(path.Dir(w.Filename))
+	if err != nil {
+		return
+	}
+
+	state := DiskOK
+	if w.DiskHardLimit > 0 && free < w.DiskHardLimit {
+		state = DiskFull
+	} else if w.DiskSoftLimit > 0 && free < w.DiskSoftLimit {
+		state = DiskLow
+	}
+
+	old := DiskState(atomic.SwapInt32(&w.diskState, int32(state)))
+	if old == state {
+		return
+	}
+
+	// alert once per state change, not per line
+	fmt.Fprintf(os.Stderr, "WriterFile(%q): disk state %s -> %s, %d bytes free\n", w.Filename, old, state, free)
+	if w.OnDiskState != nil {
+		w.OnDiskState(w, state, free)
+	}
+}
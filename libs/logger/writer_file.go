@@ -47,6 +47,20 @@ type WriterFile struct {
 	// like "project.log", project is fileNameOnly and .log is suffix
 	fileNameOnly string
 	suffix       string
+
+	// Disk guard, enabled when one of the limits is set, see NewLogWriter
+	// DiskSoftLimit below this many free bytes only Warn and above are written
+	DiskSoftLimit uint64
+	// DiskHardLimit below this many free bytes file writes are paused
+	DiskHardLimit uint64
+	// DiskCheckInterval how often free space is checked, default 10s
+	DiskCheckInterval time.Duration
+	// OnDiskState called once per disk state change
+	OnDiskState func(w *WriterFile, state DiskState, free uint64)
+
+	// wrapped the logger writes through WriterFile instead of the raw file
+	wrapped   bool
+	diskState int32
 }
 
 // NewLogFile create a LogWriter returning as os.File.
@@ -90,7 +104,9 @@ func (w *WriterFile) initLogFile() error {
 	if w.FileWriter != nil {
 		w.FileWriter.Close()
 	}
-	w.logger.SetOutput(file)
+	if !w.wrapped {
+		w.logger.SetOutput(file)
+	}
 	w.FileWriter = file
 	return w.initFd()
 }
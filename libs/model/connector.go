@@ -1,11 +1,16 @@
 package model
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"init-golang/libs/config"
 	"log"
+	"os"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -17,9 +22,24 @@ type MarketDB struct {
 	Host        string `json:"host"`
 	Port        int32  `json:"port"`
 	DBName      string `json:"dbname"`
-	Connections int    `json:"connections"`
+	Connections int    `json:"connections"` // 已废弃, 同 MaxOpenConns
 
-	conn *gorm.DB
+	MaxOpenConns    int               `json:"max_open_conns"`
+	MaxIdleConns    int               `json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration     `json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration     `json:"conn_max_idle_time"`
+	DialTimeout     time.Duration     `json:"dial_timeout"`
+	ReadTimeout     time.Duration     `json:"read_timeout"`
+	WriteTimeout    time.Duration     `json:"write_timeout"`
+	TLSMode         string            `json:"tls_mode"` // disable, preferred, skip-verify, verify
+	TLSCA           string            `json:"tls_ca"`
+	Charset         string            `json:"charset"`
+	Collation       string            `json:"collation"`
+	Timezone        string            `json:"timezone"`
+	Params          map[string]string `json:"params"`
+
+	conn         *gorm.DB
+	instrumented bool
 }
 
 // GetConnection 获取数据库连接实例
@@ -36,13 +56,84 @@ func (mdb *MarketDB) Close() (err error) {
 	return
 }
 
+// tlsConfig DSN 中的 tls 参数, verify 模式指定 CA 时注册自定义 TLS 配置
+func (mdb *MarketDB) tlsConfig() (string, error) {
+	switch mdb.TLSMode {
+	case "", "disable":
+		return "false", nil
+	case "preferred", "skip-verify":
+		return mdb.TLSMode, nil
+	case "verify":
+		if mdb.TLSCA == "" {
+			return "true", nil
+		}
+
+		pem, err := os.ReadFile(mdb.TLSCA)
+		if err != nil {
+			return "", err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return "", fmt.Errorf("no certificate found in %s", mdb.TLSCA)
+		}
+
+		name := "market-" + mdb.Host
+		err = mysqldriver.RegisterTLSConfig(name, &tls.Config{RootCAs: pool, ServerName: mdb.Host})
+		return name, err
+	}
+	return "", fmt.Errorf("unknown tls mode %q", mdb.TLSMode)
+}
+
+// DSN 连接字符串
+func (mdb *MarketDB) DSN() (string, error) {
+	cfg := mysqldriver.NewConfig()
+	cfg.User = mdb.UserName
+	cfg.Passwd = mdb.Password
+	cfg.Net = "tcp"
+	cfg.Addr = fmt.Sprintf("%s:%d", mdb.Host, mdb.Port)
+	cfg.DBName = mdb.DBName
+	cfg.ParseTime = true
+	cfg.Timeout = mdb.DialTimeout
+	cfg.ReadTimeout = mdb.ReadTimeout
+	cfg.WriteTimeout = mdb.WriteTimeout
+	if mdb.Collation != "" {
+		cfg.Collation = mdb.Collation
+	}
+
+	cfg.Loc = time.Local
+	if mdb.Timezone != "" {
+		loc, err := time.LoadLocation(mdb.Timezone)
+		if err != nil {
+			return "", err
+		}
+		cfg.Loc = loc
+	}
+
+	tlsName, err := mdb.tlsConfig()
+	if err != nil {
+		return "", err
+	}
+	cfg.TLSConfig = tlsName
+
+	charset := mdb.Charset
+	if charset == "" {
+		charset = "utf8"
+	}
+	cfg.Params = map[string]string{"charset": charset}
+	for k, v := range mdb.Params {
+		cfg.Params[k] = v
+	}
+
+	return cfg.FormatDSN(), nil
+}
+
 // Connect 链接数据库
 func (mdb *MarketDB) Connect() bool {
-	dsn := fmt.Sprintf(
-		"%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=True&loc=Local",
-		mdb.UserName, mdb.Password,
-		mdb.Host, mdb.Port, mdb.DBName,
-	)
+	dsn, err := mdb.DSN()
+	if err != nil {
+		log.Println("invalid database config:", err)
+		return false
+	}
 	conn, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
 
 	if err != nil {
@@ -57,10 +148,26 @@ func (mdb *MarketDB) Connect() bool {
 		return false
 	}
 
-	sqlDB.SetConnMaxLifetime(time.Minute * 3)
-	if mdb.Connections > 0 {
-		sqlDB.SetMaxOpenConns(mdb.Connections)
-		sqlDB.SetMaxIdleConns(mdb.Connections * 2)
+	maxOpen := mdb.MaxOpenConns
+	if maxOpen == 0 {
+		maxOpen = mdb.Connections
+	}
+	maxIdle := mdb.MaxIdleConns
+	if maxIdle == 0 {
+		maxIdle = maxOpen
+	}
+	lifetime := mdb.ConnMaxLifetime
+	if lifetime == 0 {
+		lifetime = time.Minute * 3
+	}
+
+	sqlDB.SetConnMaxLifetime(lifetime)
+	sqlDB.SetConnMaxIdleTime(mdb.ConnMaxIdleTime)
+	if maxOpen > 0 {
+		sqlDB.SetMaxOpenConns(maxOpen)
+	}
+	if maxIdle > 0 {
+		sqlDB.SetMaxIdleConns(maxIdle)
 	}
 
 	mdb.conn = conn
@@ -71,33 +178,157 @@ func (mdb *MarketDB) Connect() bool {
 type MarketMem struct {
 }
 
+// MarketRedis Market 缓存连接配置(redis), 支持单节点, 哨兵和集群模式
 type MarketRedis struct {
-	Addr     string `json:"addr"`
-	Database int    `json:"database"`
-	Pwd      string `json:"pwd"`
+	Mode       string   `json:"mode"` // single, sentinel, cluster
+	Addr       string   `json:"addr"`
+	Addrs      []string `json:"addrs"`
+	MasterName string   `json:"master_name"`
+	Database   int      `json:"database"`
+	Username   string   `json:"username"`
+	Pwd        string   `json:"pwd"`
+
+	SentinelPassword string `json:"sentinel_password"`
+
+	TLS           bool   `json:"tls"`
+	TLSSkipVerify bool   `json:"tls_skip_verify"`
+	TLSCA         string `json:"tls_ca"`
+
+	PoolSize     int           `json:"pool_size"`
+	MinIdleConns int           `json:"min_idle_conns"`
+	PoolTimeout  time.Duration `json:"pool_timeout"`
+	DialTimeout  time.Duration `json:"dial_timeout"`
+	ReadTimeout  time.Duration `json:"read_timeout"`
+	WriteTimeout time.Duration `json:"write_timeout"`
+	MaxRetries   int           `json:"max_retries"`
+
+	conn redis.UniversalClient
+	done chan struct{} // 停止上报连接池状态
+}
 
-	conn *redis.Client
+func (mredis *MarketRedis) tlsConfig() (*tls.Config, error) {
+	if !mredis.TLS {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: mredis.TLSSkipVerify}
+	if mredis.TLSCA != "" {
+		pem, err := os.ReadFile(mredis.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", mredis.TLSCA)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
 }
 
 func (mredis *MarketRedis) ConnectRedis() bool {
-	opt := redis.Options{
-		Addr:     mredis.Addr,
-		Password: mredis.Pwd,
-		DB:       mredis.Database,
+	tlsConfig, err := mredis.tlsConfig()
+	if err != nil {
+		log.Println("invalid redis tls config:", err)
+		return false
+	}
+
+	addrs := mredis.Addrs
+	if len(addrs) == 0 && mredis.Addr != "" {
+		addrs = []string{mredis.Addr}
+	}
+
+	opt := redis.UniversalOptions{
+		Addrs:            addrs,
+		DB:               mredis.Database,
+		Username:         mredis.Username,
+		Password:         mredis.Pwd,
+		SentinelPassword: mredis.SentinelPassword,
+		MasterName:       mredis.MasterName,
+		TLSConfig:        tlsConfig,
+		PoolSize:         mredis.PoolSize,
+		MinIdleConns:     mredis.MinIdleConns,
+		PoolTimeout:      mredis.PoolTimeout,
+		DialTimeout:      mredis.DialTimeout,
+		ReadTimeout:      mredis.ReadTimeout,
+		WriteTimeout:     mredis.WriteTimeout,
+		MaxRetries:       mredis.MaxRetries,
 	}
 
-	redisClient := redis.NewClient(&opt)
-	mredis.conn = redisClient
+	switch mredis.Mode {
+	case "", "single":
+		mredis.conn = redis.NewClient(opt.Simple())
+	case "sentinel":
+		mredis.conn = redis.NewFailoverClient(opt.Failover())
+	case "cluster":
+		mredis.conn = redis.NewClusterClient(opt.Cluster())
+	default:
+		log.Println("unknown redis mode:", mredis.Mode)
+		return false
+	}
 
 	return true
 }
 
 func (mredis *MarketRedis) Close() (err error) {
+	if mredis.done != nil {
+		close(mredis.done)
+		mredis.done = nil
+	}
 	err = mredis.conn.Close()
 	return
 }
 
 // GetConnection 获取数据库连接实例
-func (mredis *MarketRedis) GetRedisConnection() *redis.Client {
+func (mredis *MarketRedis) GetRedisConnection() redis.UniversalClient {
 	return mredis.conn
 }
+
+// NewMarketDB 数据库配置转换为连接配置
+func NewMarketDB(cfg config.MySQL) *MarketDB {
+	return &MarketDB{
+		UserName:        cfg.UserName,
+		Password:        cfg.Password,
+		Host:            cfg.Host,
+		Port:            cfg.Port,
+		DBName:          cfg.DBName,
+		Connections:     cfg.Connections,
+		MaxOpenConns:    cfg.MaxOpenConns,
+		MaxIdleConns:    cfg.MaxIdleConns,
+		ConnMaxLifetime: cfg.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+		DialTimeout:     cfg.DialTimeout,
+		ReadTimeout:     cfg.ReadTimeout,
+		WriteTimeout:    cfg.WriteTimeout,
+		TLSMode:         cfg.TLSMode,
+		TLSCA:           cfg.TLSCA,
+		Charset:         cfg.Charset,
+		Collation:       cfg.Collation,
+		Timezone:        cfg.Timezone,
+		Params:          cfg.Params,
+	}
+}
+
+// NewMarketRedis 缓存配置转换为连接配置
+func NewMarketRedis(cfg config.Redis) *MarketRedis {
+	return &MarketRedis{
+		Mode:             cfg.Mode,
+		Addr:             cfg.Addr,
+		Addrs:            cfg.Addrs,
+		MasterName:       cfg.MasterName,
+		Database:         cfg.Database,
+		Username:         cfg.Username,
+		Pwd:              cfg.Pwd,
+		SentinelPassword: cfg.SentinelPassword,
+		TLS:              cfg.TLS,
+		TLSSkipVerify:    cfg.TLSSkipVerify,
+		TLSCA:            cfg.TLSCA,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		PoolTimeout:      cfg.PoolTimeout,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		MaxRetries:       cfg.MaxRetries,
+	}
+}
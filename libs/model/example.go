@@ -11,6 +11,11 @@ type ExampleSettings struct {
 	LogLevel int16 `gorm:"column:log_level;TINYINT(4);NOT NULL;DEFAULT 0;" json:"log_level" mapstructure:"log_level"`
 }
 
+// TableName 表名
+func (ExampleSettings) TableName() string {
+	return "example"
+}
+
 // ExampleDB
 type ExampleDB struct {
 	MarketDB *MarketDB
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"errors"
+	"init-golang/libs/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// instrumentStartKey 查询开始时间在 gorm.DB 实例中的 key
+const instrumentStartKey = "instrument:start"
+
+// Instrument 注册 gorm 回调, 通过 GetConnection 执行的每个查询都计入 mtx 的 API 计数和耗时,
+// api 标签为 mysql.<操作>.<表名>, 记录不存在不计为错误. 耗时超过 slow 的查询写入 logger, 不含参数值, slow 为 0 不记录.
+func (mdb *MarketDB) Instrument(svc string, mtx *config.Metrics, logger *config.CFLogger, slow time.Duration) error {
+	if mdb.instrumented {
+		return nil
+	}
+
+	before := func(db *gorm.DB) {
+		db.InstanceSet(instrumentStartKey, time.Now())
+	}
+	after := func(op string) func(db *gorm.DB) {
+		return func(db *gorm.DB) {
+			value, ok := db.InstanceGet(instrumentStartKey)
+			if !ok {
+				return
+			}
+			begin := value.(time.Time)
+
+			table := db.Statement.Table
+			if table == "" {
+				table = "unknown"
+			}
+			api := "mysql." + op + "." + table
+
+			err := db.Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				err = nil
+			}
+			mtx.AddApiCounter(svc, api, err)
+			mtx.SetApiSummary(svc, api, err, begin)
+
+			if elapsed := time.Since(begin); slow > 0 && elapsed >= slow && logger != nil {
+				// 只记录带占位符的语句和参数个数, 参数值可能包含用户数据和密钥
+				logger.Warn("slow query %s %s rows %d: %s (%d args)", elapsed, api, db.RowsAffected, db.Statement.SQL.String(), len(db.Statement.Vars))
+			}
+		}
+	}
+
+	type registerer interface {
+		Register(name string, fn func(*gorm.DB)) error
+	}
+
+	callback := mdb.conn.Callback()
+	for _, item := range []struct {
+		op     string
+		before registerer
+		after  registerer
+	}{
+		{"create", callback.Create().Before("gorm:create"), callback.Create().After("gorm:create")},
+		{"query", callback.Query().Before("gorm:query"), callback.Query().After("gorm:query")},
+		{"update", callback.Update().Before("gorm:update"), callback.Update().After("gorm:update")},
+		{"delete", callback.Delete().Before("gorm:delete"), callback.Delete().After("gorm:delete")},
+		{"row", callback.Row().Before("gorm:row"), callback.Row().After("gorm:row")},
+		{"raw", callback.Raw().Before("gorm:raw"), callback.Raw().After("gorm:raw")},
+	} {
+		if err := item.before.Register("instrument:before_"+item.op, before); err != nil {
+			return err
+		}
+		if err := item.after.Register("instrument:after_"+item.op, after(item.op)); err != nil {
+			return err
+		}
+	}
+
+	mdb.instrumented = true
+	return nil
+}
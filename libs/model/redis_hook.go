@@ -0,0 +1,120 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"init-golang/libs/config"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// redisPoolInterval 连接池状态上报周期
+const redisPoolInterval = time.Second * 5
+
+type redisStartKey struct{}
+
+// redisHook 记录 Redis 命令的计数, 耗时和错误, redis.Nil 不计为错误
+type redisHook struct {
+	svc    string
+	mtx    *config.Metrics
+	logger *config.CFLogger
+}
+
+var _ redis.Hook = (*redisHook)(nil)
+
+func (hook *redisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+func (hook *redisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	begin, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+
+	api := "redis." + cmd.Name()
+	err := redisError(cmd.Err())
+	hook.mtx.AddApiCounter(hook.svc, api, err)
+	hook.mtx.SetApiSummary(hook.svc, api, err, begin)
+
+	if hook.logger != nil && hook.logger.IsTraceEnabled() {
+		hook.logger.Trace("redis %s %s err %v", cmd.Name(), time.Since(begin), err)
+	}
+	return nil
+}
+
+func (hook *redisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, redisStartKey{}, time.Now()), nil
+}
+
+// AfterProcessPipeline pipeline 整体计为 redis.pipeline, 其中每个命令单独计数
+func (hook *redisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	begin, ok := ctx.Value(redisStartKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	for _, cmd := range cmds {
+		cmdErr := redisError(cmd.Err())
+		if err == nil {
+			err = cmdErr
+		}
+		hook.mtx.AddApiCounter(hook.svc, "redis."+cmd.Name(), cmdErr)
+	}
+
+	hook.mtx.AddApiCounter(hook.svc, "redis.pipeline", err)
+	hook.mtx.SetApiSummary(hook.svc, "redis.pipeline", err, begin)
+	hook.mtx.ObservePipelineSize(hook.svc, len(cmds))
+
+	if hook.logger != nil && hook.logger.IsTraceEnabled() {
+		hook.logger.Trace("redis pipeline of %d commands %s err %v", len(cmds), time.Since(begin), err)
+	}
+	return nil
+}
+
+// redisError redis.Nil 表示 key 不存在, 不是错误
+func redisError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
+
+// Instrument 注册 Redis hook, 每个命令计入 mtx 的 API 计数和耗时, api 标签为 redis.<命令>,
+// pipeline 计为 redis.pipeline 并记录命令数. 连接池状态定期上报为 service_status{name="redis_pool"}.
+// logger 不为空且开启 Trace 级别时输出每个命令的耗时.
+func (mredis *MarketRedis) Instrument(svc string, mtx *config.Metrics, logger *config.CFLogger) error {
+	if mredis.done != nil {
+		return nil
+	}
+
+	mredis.conn.AddHook(&redisHook{svc: svc, mtx: mtx, logger: logger})
+
+	done := make(chan struct{})
+	mredis.done = done
+	go func() {
+		ticker := time.NewTicker(redisPoolInterval)
+		defer ticker.Stop()
+		for {
+			reportRedisPool(svc, mtx, mredis.conn.PoolStats())
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+func reportRedisPool(svc string, mtx *config.Metrics, stats *redis.PoolStats) {
+	mtx.SetSvcValue(svc, "redis_pool", "hits", float64(stats.Hits))
+	mtx.SetSvcValue(svc, "redis_pool", "misses", float64(stats.Misses))
+	mtx.SetSvcValue(svc, "redis_pool", "timeouts", float64(stats.Timeouts))
+	mtx.SetSvcValue(svc, "redis_pool", "total_conns", float64(stats.TotalConns))
+	mtx.SetSvcValue(svc, "redis_pool", "idle_conns", float64(stats.IdleConns))
+	mtx.SetSvcValue(svc, "redis_pool", "stale_conns", float64(stats.StaleConns))
+}
@@ -0,0 +1,12 @@
+-- 功能开关, 见 config_example.yaml flags
+CREATE TABLE IF NOT EXISTS `feature_flag` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL COMMENT '开关名称',
+  `service_id` varchar(64) NOT NULL DEFAULT '' COMMENT '服务ID, 为空对全部服务生效',
+  `symbol` varchar(32) NOT NULL DEFAULT '' COMMENT '交易对, 为空对全部交易对生效',
+  `enabled` tinyint(1) NOT NULL DEFAULT 0 COMMENT '是否开启',
+  `percent` tinyint unsigned NOT NULL DEFAULT 0 COMMENT '灰度比例, 0 或 100 为全量',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_flag_scope` (`name`, `service_id`, `symbol`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
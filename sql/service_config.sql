@@ -0,0 +1,10 @@
+-- 远程配置, 见 config_example.yaml remote
+CREATE TABLE IF NOT EXISTS `service_config` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `service_id` varchar(64) NOT NULL COMMENT '服务名, 对应配置 name',
+  `config_key` varchar(128) NOT NULL COMMENT '配置项路径, 如 monitor.error_details',
+  `config_value` text NOT NULL COMMENT '配置值',
+  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_service_key` (`service_id`, `config_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -18,3 +18,19 @@ const (
 	STATUS_STOPPING                     // 停止中
 	STATUS_STOPPED                      // 已停止
 )
+
+var statusNames = map[ServerStatus]string{
+	STATUS_INITTED:  "initted",
+	STATUS_PENDING:  "pending",
+	STATUS_STARTING: "starting",
+	STATUS_STARTED:  "started",
+	STATUS_STOPPING: "stopping",
+	STATUS_STOPPED:  "stopped",
+}
+
+func (status ServerStatus) String() string {
+	if name, ok := statusNames[status]; ok {
+		return name
+	}
+	return "unknown"
+}
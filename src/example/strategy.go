@@ -1,10 +1,14 @@
 package example
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"init-golang/libs/config"
+	"init-golang/libs/health"
 	"init-golang/libs/model"
 	"log"
-	"os"
+	"sync"
 	"time"
 )
 
@@ -20,8 +24,12 @@ type Strategy struct {
 	Mrds   *model.MarketRedis
 	Mtx    *config.Metrics
 
+	mu            sync.RWMutex
 	status        ServerStatus
 	serviceConfig model.ExampleSettings
+	done          chan struct{} // 关闭时 run 退出
+	stopped       chan struct{} // run 退出后关闭
+	err           error         // 最近一次启动或运行失败的原因
 }
 
 func NewStrategy() *Strategy {
@@ -50,20 +58,45 @@ func (ins *Strategy) Configure(serviceID string, apiCfg config.MarketAPI, logger
 }
 
 func (ins *Strategy) SetStatus(status ServerStatus) {
+	ins.mu.Lock()
 	ins.status = status
+	ins.mu.Unlock()
 
-	ins.Mtx.SetSvcValue(ins.ServiceID, "status", "service_status", float64(ins.status))
+	ins.Mtx.SetSvcValue(ins.ServiceID, "status", "service_status", float64(status))
 }
 
-func (ins *Strategy) Start() {
-	if ins.status != STATUS_PENDING && ins.status != STATUS_STOPPED {
+// Status 当前服务状态
+func (ins *Strategy) Status() ServerStatus {
+	ins.mu.RLock()
+	defer ins.mu.RUnlock()
+
+	return ins.status
+}
+
+// Err 最近一次启动或运行失败的原因, 读取服务配置成功后清除
+func (ins *Strategy) Err() error {
+	ins.mu.RLock()
+	defer ins.mu.RUnlock()
+	return ins.err
+}
+
+func (ins *Strategy) setErr(err error) {
+	ins.mu.Lock()
+	ins.err = err
+	ins.mu.Unlock()
+}
+
+// Start 读取服务配置并启动, 失败时返回 false 并回到停止状态, 可以再次 Start
+func (ins *Strategy) Start() bool {
+	if status := ins.Status(); status != STATUS_PENDING && status != STATUS_STOPPED {
 		log.Printf("status not pending or stopped\n")
-		return
+		return false
 	}
 	ins.SetStatus(STATUS_STARTING)
 
 	if !ins.Update() {
-		os.Exit(1)
+		ins.SetStatus(STATUS_STOPPED)
+		return false
 	}
 
 	// ticker := time.NewTicker(time.Second * 5)
@@ -82,14 +115,43 @@ func (ins *Strategy) Start() {
 
 	ins.Mtx.SetSvcValue(ins.ServiceID, "process", "start_time", float64(time.Now().Unix()))
 
-	go func() {
-		for {
-			if ins.status == STATUS_STARTED && ins.serviceConfig.Status == 1 {
-				ins.main()
-				time.Sleep(time.Millisecond * time.Duration(ins.serviceConfig.TickTime))
+	ins.done = make(chan struct{})
+	ins.stopped = make(chan struct{})
+	go ins.run(ins.done, ins.stopped)
+
+	return true
+}
+
+// run 按 TickTime 周期执行业务主逻辑, done 关闭时退出并关闭 stopped.
+// 业务逻辑 panic 时记录原因并进入停止状态, 可以再次 Start.
+func (ins *Strategy) run(done chan struct{}, stopped chan struct{}) {
+	defer close(stopped)
+
+	for {
+		ins.mu.RLock()
+		serviceConfig := ins.serviceConfig
+		ins.mu.RUnlock()
+
+		if serviceConfig.Status == 1 {
+			if err := ins.safeMain(); err != nil {
+				ins.Logger.Error("service crashed: %s", err)
+				ins.setErr(err)
+				ins.SetStatus(STATUS_STOPPED)
+				return
 			}
 		}
-	}()
+
+		tickTime := time.Millisecond * time.Duration(serviceConfig.TickTime)
+		if tickTime <= 0 {
+			tickTime = time.Second
+		}
+
+		select {
+		case <-done:
+			return
+		case <-time.After(tickTime):
+		}
+	}
 }
 
 func (ins *Strategy) Update() bool {
@@ -97,15 +159,22 @@ func (ins *Strategy) Update() bool {
 	serviceConfig, err := ins.Mdb.GetConfig(ins.ServiceID)
 	if err != nil || (serviceConfig == model.ExampleSettings{}) {
 		ins.Logger.Error("get service config failed")
+		if err == nil {
+			err = errors.New("service config not found")
+		}
+		ins.setErr(fmt.Errorf("get service config: %w", err))
 		return false
 	}
+	ins.setErr(nil)
 
 	if (ins.serviceConfig == model.ExampleSettings{}) || ins.serviceConfig.LogLevel != serviceConfig.LogLevel {
 		ins.Logger.SetLevel(serviceConfig.LogLevel)
 	}
+	ins.mu.Lock()
 	ins.serviceConfig = serviceConfig
+	ins.mu.Unlock()
 
-	ins.Logger.Debug("service config loaded %v", ins.serviceConfig)
+	ins.Logger.Debug("service config loaded %v", serviceConfig)
 
 	ins.Mtx.SetSvcValue(ins.ServiceID, "process", "update_time", float64(time.Now().Unix()))
 
@@ -113,17 +182,49 @@ func (ins *Strategy) Update() bool {
 }
 
 func (ins *Strategy) Stop() {
-	if ins.status != STATUS_STARTED {
+	if ins.Status() != STATUS_STARTED {
 		log.Printf("status not started\n")
 		return
 	}
 	ins.SetStatus(STATUS_STOPPING)
 
+	// 等待正在执行的业务逻辑完成
+	close(ins.done)
+	<-ins.stopped
+
 	ins.Mtx.SetSvcValue(ins.ServiceID, "process", "stop_time", float64(time.Now().Unix()))
 
 	ins.SetStatus(STATUS_STOPPED)
 }
 
+// Checks 服务的就绪检查, 注册为 /readyz 的 <serviceID>.<name> 检查项.
+// 依赖外部数据的策略在这里添加自己的检查, 如行情推送是否中断.
+func (ins *Strategy) Checks() map[string]health.Check {
+	return map[string]health.Check{
+		"status": ins.checkStatus,
+	}
+}
+
+// checkStatus 服务已启动
+func (ins *Strategy) checkStatus(ctx context.Context) error {
+	if status := ins.Status(); status != STATUS_STARTED {
+		return fmt.Errorf("status %s", status)
+	}
+	return nil
+}
+
+// safeMain 执行业务主逻辑, panic 转换为错误
+func (ins *Strategy) safeMain() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ins.main()
+	return nil
+}
+
 // 业务主逻辑
 func (ins *Strategy) main() {
 	ins.Logger.Trace("run main logic")